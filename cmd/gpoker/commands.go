@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gpoker/pkg/client"
+	"gpoker/pkg/game"
+	"io"
+	"strconv"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// usageError is an error in command line arguments.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// cli runs client commands.
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+// clientFlags are flags common to all client commands.
+type clientFlags struct {
+	server string
+	token  string
+	json   bool
+}
+
+// flagSet creates flags of a client command with the common ones already defined.
+func (c cli) flagSet(name, arguments string) (*flag.FlagSet, *clientFlags) {
+	common := &clientFlags{}
+	flags := flag.NewFlagSet("gpoker "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: gpoker %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	server := c.getenv(envPrefix + "SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	flags.StringVar(&common.server, "server", server, "URL of the server, "+envPrefix+"SERVER variable")
+	flags.StringVar(&common.token, "token", c.getenv(envPrefix+"TOKEN"), "session token, "+envPrefix+"TOKEN variable")
+	flags.BoolVar(&common.json, "json", false, "print JSON instead of tables")
+	return flags, common
+}
+
+// parse parses args and checks that exactly n positional arguments are left.
+func parse(flags *flag.FlagSet, args []string, n int) error {
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() != n {
+		flags.Usage()
+		return usageError{fmt.Errorf("expected %d arguments, got %d", n, flags.NArg())}
+	}
+	return nil
+}
+
+func (f *clientFlags) client() (*client.Client, error) {
+	c, err := client.New(f.server, nil)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	return c.WithToken(f.token), nil
+}
+
+func (c cli) signup(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("signup", "NAME")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	signup, err := api.Signup(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if common.json {
+		return printJSON(c.stdout, signup)
+	}
+	return printSignup(c.stdout, signup)
+}
+
+func (c cli) games(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{errors.New("expected a subcommand: list, create, show or export")}
+	}
+	switch args[0] {
+	case "list":
+		return c.listGames(ctx, args[1:])
+	case "create":
+		return c.createGame(ctx, args[1:])
+	case "show":
+		return c.showGame(ctx, args[1:])
+	case "export":
+		return c.exportGame(ctx, args[1:])
+	default:
+		return usageError{fmt.Errorf("unknown subcommand %q, expected list, create, show or export", args[0])}
+	}
+}
+
+func (c cli) listGames(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("games list", "")
+	var req game.ListGamesRequest
+	var createdBy, member uint64
+	flags.StringVar(&req.Name, "name", "", "only games with this text in the name")
+	flags.Uint64Var(&createdBy, "created-by", 0, "only games created by this player ID")
+	flags.Uint64Var(&member, "member", 0, "only games this player ID is in")
+	flags.StringVar((*string)(&req.Status), "status", "", "active, archived or all, active by default")
+	flags.StringVar((*string)(&req.Sort), "sort", "", "name, created or activity, prefixed with - for descending order")
+	flags.IntVar(&req.Limit, "limit", 0, "games per page, the server's default if not set")
+	flags.StringVar(&req.Cursor, "cursor", "", "cursor of the next page printed by the previous command")
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	req.CreatedBy, req.Member = game.PlayerID(createdBy), game.PlayerID(member)
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	games, err := api.ListGames(ctx, req)
+	if err != nil {
+		return err
+	}
+	if common.json {
+		return printJSON(c.stdout, games)
+	}
+	return printGames(c.stdout, games)
+}
+
+func (c cli) createGame(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("games create", "NAME")
+	var deck, cards string
+	flags.StringVar(&deck, "deck", "", "deck: fibonacci, modified_fibonacci, tshirt, powers_of_two or custom")
+	flags.StringVar(&cards, "cards", "", "comma separated cards of a custom deck")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	req := game.CreatePokerRequest{GameName: flags.Arg(0), Deck: game.DeckType(deck)}
+	for _, card := range splitList(cards) {
+		req.Cards = append(req.Cards, game.Vote(card))
+	}
+	poker, err := api.CreateGame(ctx, req)
+	if err != nil {
+		return err
+	}
+	if common.json {
+		return printJSON(c.stdout, poker)
+	}
+	return printGame(c.stdout, poker)
+}
+
+func (c cli) showGame(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("games show", "GAME_ID")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	gameID, err := parseGameID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	poker, err := api.GetGame(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	if common.json {
+		return printJSON(c.stdout, poker)
+	}
+	return printGame(c.stdout, poker)
+}
+
+func (c cli) exportGame(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("games export", "GAME_ID")
+	var format string
+	flags.StringVar(&format, "format", string(game.ExportCSV), "export format: csv, json or md")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	gameID, err := parseGameID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	return api.ExportGame(ctx, gameID, game.ExportFormat(format), c.stdout)
+}
+
+func (c cli) join(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("join", "GAME_ID|CODE")
+	var role string
+	flags.StringVar(&role, "role", string(game.RoleVoter), "role in the game: voter or observer")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	gameID, err := parseGameID(flags.Arg(0))
+	if err != nil { // not an ID, so it must be a join code
+		poker, err := api.JoinGameByCode(ctx, flags.Arg(0), game.Role(role))
+		if err != nil {
+			return err
+		}
+		return c.printGameResponse(poker, common.json)
+	}
+	if err = api.JoinGame(ctx, gameID, game.Role(role)); err != nil {
+		return err
+	}
+	return c.printGameOf(ctx, api, gameID, common.json)
+}
+
+func (c cli) vote(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("vote", "GAME_ID CARD")
+	if err := parse(flags, args, 2); err != nil {
+		return err
+	}
+	gameID, err := parseGameID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	if err = api.Vote(ctx, gameID, game.Vote(flags.Arg(1))); err != nil {
+		return err
+	}
+	return c.printGameOf(ctx, api, gameID, common.json)
+}
+
+// watch prints the game every time it changes until ctx is done or the game is deleted. With -json every event is
+// printed on its own line instead.
+func (c cli) watch(ctx context.Context, args []string) error {
+	flags, common := c.flagSet("watch", "GAME_ID")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	gameID, err := parseGameID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := common.client()
+	if err != nil {
+		return err
+	}
+	sub, err := api.Subscribe(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	var watched watchedGame
+	for event := range sub.Events() {
+		if err = watched.apply(event); err != nil {
+			return err
+		}
+		if common.json {
+			err = printJSONLine(c.stdout, event)
+		} else {
+			err = c.printEvent(event, watched)
+		}
+		if err != nil {
+			return err
+		}
+		if watched.ended { // the server closes the connection, that's not an error
+			return nil
+		}
+	}
+	if err = sub.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// printEvent prints the time and type of an event followed by the state of the game after it.
+func (c cli) printEvent(event game.Event, watched watchedGame) error {
+	if event.Type == game.EventTimerTick { // every second, the whole game would flood the screen
+		var tick game.TimerEvent
+		if err := json.Unmarshal(event.Payload, &tick); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(c.stdout, "[%s] %s %s left\n", event.Time.Local().Format("15:04:05"), event.Type,
+			formatRemaining(tick.Remaining))
+		return err
+	}
+	fmt.Fprintf(c.stdout, "[%s] %s\n", event.Time.Local().Format("15:04:05"), event.Type)
+	if watched.ended {
+		return nil
+	}
+	if err := printGame(c.stdout, watched.GameResponse); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(c.stdout)
+	return err
+}
+
+func (c cli) printGameOf(ctx context.Context, api *client.Client, gameID game.GameID, asJSON bool) error {
+	poker, err := api.GetGame(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	return c.printGameResponse(poker, asJSON)
+}
+
+func (c cli) printGameResponse(poker game.GameResponse, asJSON bool) error {
+	if asJSON {
+		return printJSON(c.stdout, poker)
+	}
+	return printGame(c.stdout, poker)
+}
+
+func parseGameID(s string) (game.GameID, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, usageError{fmt.Errorf("invalid game ID %q", s)}
+	}
+	return game.GameID(id), nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/client"
+	"gpoker/pkg/game"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The server listens on its own port, so these tests can run in parallel with tests of other packages.
+const testServer = "http://localhost:8082"
+
+func TestCommands(t *testing.T) {
+	startServer(t)
+	ctx := context.Background()
+
+	var signup game.SignupResponse
+	runJSON(t, nil, &signup, "signup", "-json", "bobby")
+	require.Equal(t, "bobby", signup.Name)
+	bobby := map[string]string{"GPOKER_TOKEN": signup.Token}
+
+	out := runOK(t, bobby, "games", "create", "-deck", "custom", "-cards", "S, M,L", "sprint")
+	require.Contains(t, out, `Game 1 "sprint", voting, custom deck: S M L`)
+	out = runOK(t, nil, "games", "list")
+	require.Equal(t, "ID  NAME    PLAYERS  STATE\n1   sprint  1        voting\n", out)
+	runOK(t, bobby, "games", "create", "retro")
+	out = runOK(t, nil, "games", "list", "-sort", "-name", "-limit", "1")
+	require.Regexp(t, `^ID  NAME    PLAYERS  STATE\n1   sprint  1        voting\nMore games: -cursor \S+\n$`, out)
+
+	out = runOK(t, nil, "signup", "alice")
+	require.Contains(t, out, "NAME     alice")
+	alice := map[string]string{"GPOKER_TOKEN": tokenFromTable(t, out)}
+	runOK(t, alice, "join", "1")
+	runOK(t, alice, "vote", "1", "M")
+	runOK(t, bobby, "vote", "1", "L")
+
+	api, err := client.New(testServer, nil)
+	require.NoError(t, err)
+	created, err := api.WithToken(signup.Token).GetGame(ctx, 1)
+	require.NoError(t, err)
+	carol := map[string]string{"GPOKER_TOKEN": tokenFromTable(t, runOK(t, nil, "signup", "carol"))}
+	out = runOK(t, carol, "join", "-role", "observer", strings.ToLower(created.Code))
+	require.Contains(t, out, "carol   observer")
+
+	out = runOK(t, bobby, "games", "show", "1")
+	require.Equal(t, `Game 1 "sprint", voting, custom deck: S M L
+Join code: `+created.Code+`
+PLAYER  ROLE         VOTED  VOTE
+alice   voter        yes    -
+bobby   facilitator  yes    -
+carol   observer     no     -
+`, out)
+	out = runOK(t, nil, "games", "show", "1")
+	require.NotContains(t, out, "Join code")
+
+	_, err = api.WithToken(signup.Token).Reveal(ctx, 1)
+	require.NoError(t, err)
+	var poker game.GameResponse
+	runJSON(t, nil, &poker, "games", "show", "--json", "1")
+	require.Equal(t, game.RoundRevealed, poker.State)
+	require.Len(t, poker.Players, 3)
+
+	out = runOK(t, nil, "games", "export", "1")
+	require.True(t, strings.HasPrefix(out, "story_id,story_key,story_title,estimate,round,"), out)
+	require.Contains(t, out, ",alice,M,2,,,false,\n")
+	out = runOK(t, nil, "games", "export", "-format", "md", "1")
+	require.True(t, strings.HasPrefix(out, "# sprint\n"), out)
+}
+
+func TestWatch(t *testing.T) {
+	startServer(t)
+	var signup game.SignupResponse
+	runJSON(t, nil, &signup, "signup", "-json", "bobby")
+	bobby := map[string]string{"GPOKER_TOKEN": signup.Token}
+	runOK(t, bobby, "games", "create", "sprint")
+
+	for _, asJSON := range []bool{false, true} {
+		t.Run(fmt.Sprintf("json %t", asJSON), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var stdout, stderr syncBuffer
+			args := []string{"watch", "-server", testServer, "1"}
+			if asJSON {
+				args = []string{"watch", "-server", testServer, "-json", "1"}
+			}
+			done := make(chan int)
+			go func() { done <- run(ctx, args, &stdout, &stderr, env(nil)) }()
+
+			waitForOutput(t, &stdout, "game_snapshot")
+			runOK(t, bobby, "vote", "1", "5")
+			if asJSON {
+				waitForOutput(t, &stdout, "vote_cast")
+			} else {
+				waitForOutput(t, &stdout, "vote_cast\nGame 1 \"sprint\", voting, fibonacci deck")
+				waitForOutput(t, &stdout, "bobby   facilitator  yes    -")
+
+				api, err := client.New(testServer, nil)
+				require.NoError(t, err)
+				_, err = api.WithToken(signup.Token).StartTimer(ctx, 1, game.TimerRequest{Seconds: 60})
+				require.NoError(t, err)
+				waitForOutput(t, &stdout, "timer_started\nGame 1 \"sprint\", voting, fibonacci deck")
+				waitForOutput(t, &stdout, "left, none on expiry")
+				waitForOutput(t, &stdout, "timer_tick 0:59 left\n")
+				require.NoError(t, api.WithToken(signup.Token).StopTimer(ctx, 1))
+				waitForOutput(t, &stdout, "timer_cancelled")
+				alice := map[string]string{"GPOKER_TOKEN": tokenFromTable(t, runOK(t, nil, "signup", "alice"))}
+				runOK(t, alice, "join", "1")
+				waitForOutput(t, &stdout, "player_joined\nGame 1 \"sprint\", voting, fibonacci deck")
+				waitForOutput(t, &stdout, "alice   voter        no     -")
+				_, err = api.WithToken(signup.Token).ImportStories(ctx, 1, "", []game.StoryRequest{{Title: "Login page"}})
+				require.NoError(t, err)
+				waitForOutput(t, &stdout, "story_started\nGame 1 \"sprint\", voting, fibonacci deck")
+				waitForOutput(t, &stdout, "Story: Login page\n")
+			}
+			cancel()
+			require.Equal(t, 0, <-done, stderr.String())
+			if asJSON {
+				lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+				require.Len(t, lines, 2)
+				var event game.Event
+				require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+				require.Equal(t, game.EventVoteCast, event.Type)
+			}
+		})
+	}
+}
+
+func TestWatchEndsWithGame(t *testing.T) {
+	startServer(t)
+	var signup game.SignupResponse
+	runJSON(t, nil, &signup, "signup", "-json", "bobby")
+	bobby := map[string]string{"GPOKER_TOKEN": signup.Token}
+	runOK(t, bobby, "games", "create", "sprint")
+	var stdout, stderr syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- run(context.Background(), []string{"watch", "-server", testServer, "1"}, &stdout, &stderr, env(nil))
+	}()
+	waitForOutput(t, &stdout, "game_snapshot")
+
+	api, err := client.New(testServer, nil)
+	require.NoError(t, err)
+	require.NoError(t, api.WithToken(signup.Token).DeleteGame(context.Background(), 1))
+	select {
+	case code := <-done:
+		require.Equal(t, 0, code, stderr.String())
+	case <-time.After(time.Second):
+		t.Fatal("watch didn't end with the game")
+	}
+	require.True(t, strings.HasSuffix(stdout.String(), "game_deleted\n"), stdout.String())
+}
+
+func TestWatchedPlayersStaySorted(t *testing.T) {
+	watched := &watchedGame{}
+	watched.Players = []game.PlayerResponse{{ID: 1, Name: "bobby"}, {ID: 3, Name: "dave"}}
+	joined := func(player game.PlayerResponse) {
+		payload, err := json.Marshal(player)
+		require.NoError(t, err)
+		require.NoError(t, watched.apply(game.Event{Type: game.EventPlayerJoined, Payload: payload}))
+	}
+	joined(game.PlayerResponse{ID: 2, Name: "carol"})
+	joined(game.PlayerResponse{ID: 4, Name: "alice"})
+	joined(game.PlayerResponse{ID: 5, Name: "bobby"})
+	joined(game.PlayerResponse{ID: 3, Name: "dave", Role: game.RoleObserver}) // rejoined with another role
+
+	require.Equal(t, []game.PlayerResponse{
+		{ID: 4, Name: "alice"},
+		{ID: 1, Name: "bobby"},
+		{ID: 5, Name: "bobby"},
+		{ID: 2, Name: "carol"},
+		{ID: 3, Name: "dave", Role: game.RoleObserver},
+	}, watched.Players)
+}
+
+func TestCommandErrors(t *testing.T) {
+	startServer(t)
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "unknown command", args: []string{"deal"}, code: 2},
+		{name: "unknown games subcommand", args: []string{"games", "delete"}, code: 2},
+		{name: "missing argument", args: []string{"vote", "1"}, code: 2},
+		{name: "bad game ID", args: []string{"games", "show", "first"}, code: 2},
+		{name: "unknown flag", args: []string{"join", "-admin", "1"}, code: 2},
+		{name: "bad server URL", args: []string{"games", "list", "-server", "localhost"}, code: 2},
+		{name: "game not found", args: []string{"games", "show", "100"}, code: 1},
+		{name: "not authenticated", args: []string{"games", "create", "sprint"}, code: 1},
+		{name: "help", args: []string{"vote", "-h"}, code: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), test.args, &stdout, &stderr, env(map[string]string{"GPOKER_SERVER": testServer}))
+			require.Equal(t, test.code, code, stderr.String())
+			if code != 0 {
+				require.NotEmpty(t, stderr.String())
+			}
+		})
+	}
+}
+
+func TestServeBadConfig(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 2, run(context.Background(), []string{"-port", "8080"}, &stdout, &stderr, env(nil)))
+	require.Contains(t, stderr.String(), "failed to load configuration")
+}
+
+// startServer starts a server at testServer that is stopped at the end of the test.
+func startServer(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.Addr = strings.TrimPrefix(testServer, "http://")
+	cfg.GinMode = "test"
+	srv, err := game.NewStartedServer(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, srv.Stop(context.Background())) })
+	api, err := client.New(testServer, nil)
+	require.NoError(t, err)
+	deadline := time.Now().Add(4 * time.Second)
+	for err = api.Health(context.Background()); err != nil; err = api.Health(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server didn't start in time, last error %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runOK runs a command against testServer, checks that it succeeded and returns its output.
+func runOK(t *testing.T, vars map[string]string, args ...string) string {
+	withServer := map[string]string{"GPOKER_SERVER": testServer}
+	for k, v := range vars {
+		withServer[k] = v
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr, env(withServer))
+	require.Equal(t, 0, code, stderr.String())
+	return stdout.String()
+}
+
+func runJSON(t *testing.T, vars map[string]string, out any, args ...string) {
+	require.NoError(t, json.Unmarshal([]byte(runOK(t, vars, args...)), out))
+}
+
+func tokenFromTable(t *testing.T, out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "TOKEN" {
+			return fields[1]
+		}
+	}
+	t.Fatalf("no token in %q", out)
+	return ""
+}
+
+func waitForOutput(t *testing.T, out *syncBuffer, substr string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), substr) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%q not found in output %q", substr, out.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written and read concurrently.
+type syncBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
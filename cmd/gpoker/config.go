@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"gpoker/pkg/game"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "GPOKER_"
+
+// loadConfig builds server configuration. Every next source overrides the previous one:
+// defaults, config file, GPOKER_* environment variables, command line flags.
+// The config file is set by -config flag or GPOKER_CONFIG variable and can be either YAML or JSON.
+func loadConfig(args []string, getenv func(string) string) (game.Config, error) {
+	cfg := game.DefaultConfig()
+	var (
+		configPath    string
+		corsOrigins   string
+		storage       string
+		gameExpiry    string
+		flagOverrides game.Config // only values of explicitly set flags are used
+	)
+	flags := flag.NewFlagSet("gpoker", flag.ContinueOnError)
+	flags.StringVar(&configPath, "config", "", "path to a YAML or JSON config file")
+	flags.StringVar(&flagOverrides.Addr, "addr", cfg.Addr, "address to listen on")
+	flags.StringVar(&corsOrigins, "cors-origins", "", "comma separated origins allowed by CORS, all if empty")
+	flags.BoolVar(&flagOverrides.WSCheckOrigin, "ws-check-origin", cfg.WSCheckOrigin, "allow websocket connections only from CORS origins")
+	flags.DurationVar(&flagOverrides.WSPingPeriod, "ws-ping-period", cfg.WSPingPeriod, "how often websocket clients are pinged")
+	flags.DurationVar(&flagOverrides.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "graceful shutdown timeout")
+	flags.StringVar(&flagOverrides.GinMode, "gin-mode", cfg.GinMode, "gin mode: debug, release or test")
+	flags.StringVar(&storage, "storage", string(cfg.Storage.Backend), "storage backend: memory or file")
+	flags.StringVar(&flagOverrides.Storage.Path, "storage-path", cfg.Storage.Path, "file for the file storage")
+	flags.DurationVar(&flagOverrides.SessionTTL, "session-ttl", cfg.SessionTTL, "how long a session token is valid")
+	flags.StringVar(&flagOverrides.LogLevel, "log-level", cfg.LogLevel, "log level: debug, info, warn or error")
+	flags.StringVar(&flagOverrides.LogFormat, "log-format", cfg.LogFormat, "log format: logfmt or json")
+	flags.StringVar(&flagOverrides.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "address to serve Prometheus metrics on, disabled if empty")
+	flags.DurationVar(&flagOverrides.GameTTL, "game-ttl", cfg.GameTTL, "how long a game can stay unchanged before it expires, never if 0")
+	flags.StringVar(&gameExpiry, "game-expiry", string(cfg.GameExpiry), "what happens to expired games: archive or delete")
+	flags.DurationVar(&flagOverrides.JanitorPeriod, "janitor-period", cfg.JanitorPeriod, "how often expired games are looked for")
+	if err := flags.Parse(args); err != nil {
+		return game.Config{}, err
+	}
+
+	if configPath == "" {
+		configPath = getenv(envPrefix + "CONFIG")
+	}
+	if configPath != "" {
+		if err := loadConfigFile(configPath, &cfg); err != nil {
+			return game.Config{}, err
+		}
+	}
+	if err := loadEnv(getenv, &cfg); err != nil {
+		return game.Config{}, err
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = flagOverrides.Addr
+		case "cors-origins":
+			cfg.CORSOrigins = splitList(corsOrigins)
+		case "ws-check-origin":
+			cfg.WSCheckOrigin = flagOverrides.WSCheckOrigin
+		case "ws-ping-period":
+			cfg.WSPingPeriod = flagOverrides.WSPingPeriod
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = flagOverrides.ShutdownTimeout
+		case "gin-mode":
+			cfg.GinMode = flagOverrides.GinMode
+		case "storage":
+			cfg.Storage.Backend = game.StorageBackend(storage)
+		case "storage-path":
+			cfg.Storage.Path = flagOverrides.Storage.Path
+		case "session-ttl":
+			cfg.SessionTTL = flagOverrides.SessionTTL
+		case "log-level":
+			cfg.LogLevel = flagOverrides.LogLevel
+		case "log-format":
+			cfg.LogFormat = flagOverrides.LogFormat
+		case "game-ttl":
+			cfg.GameTTL = flagOverrides.GameTTL
+		case "game-expiry":
+			cfg.GameExpiry = game.GameExpiry(gameExpiry)
+		case "janitor-period":
+			cfg.JanitorPeriod = flagOverrides.JanitorPeriod
+		case "metrics-addr":
+			cfg.MetricsAddr = flagOverrides.MetricsAddr
+		}
+	})
+	return cfg, nil
+}
+
+func loadConfigFile(path string, cfg *game.Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// YAML is a superset of JSON, so the same decoder works for both
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(getenv func(string) string, cfg *game.Config) error {
+	if v := getenv(envPrefix + "ADDR"); v != "" {
+		cfg.Addr = v
+	}
+	if v := getenv(envPrefix + "CORS_ORIGINS"); v != "" {
+		cfg.CORSOrigins = splitList(v)
+	}
+	if v := getenv(envPrefix + "WS_CHECK_ORIGIN"); v != "" {
+		check, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%sWS_CHECK_ORIGIN: %w", envPrefix, err)
+		}
+		cfg.WSCheckOrigin = check
+	}
+	if v := getenv(envPrefix + "WS_PING_PERIOD"); v != "" {
+		period, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sWS_PING_PERIOD: %w", envPrefix, err)
+		}
+		cfg.WSPingPeriod = period
+	}
+	if v := getenv(envPrefix + "SHUTDOWN_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sSHUTDOWN_TIMEOUT: %w", envPrefix, err)
+		}
+		cfg.ShutdownTimeout = timeout
+	}
+	if v := getenv(envPrefix + "GIN_MODE"); v != "" {
+		cfg.GinMode = v
+	}
+	if v := getenv(envPrefix + "STORAGE"); v != "" {
+		cfg.Storage.Backend = game.StorageBackend(v)
+	}
+	if v := getenv(envPrefix + "STORAGE_PATH"); v != "" {
+		cfg.Storage.Path = v
+	}
+	if v := getenv(envPrefix + "AUTH_SECRET"); v != "" {
+		cfg.AuthSecret = v
+	}
+	if v := getenv(envPrefix + "ADMIN_TOKEN"); v != "" {
+		cfg.AdminToken = v
+	}
+	if v := getenv(envPrefix + "SESSION_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sSESSION_TTL: %w", envPrefix, err)
+		}
+		cfg.SessionTTL = ttl
+	}
+	if v := getenv(envPrefix + "LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := getenv(envPrefix + "LOG_FORMAT"); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := getenv(envPrefix + "GAME_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sGAME_TTL: %w", envPrefix, err)
+		}
+		cfg.GameTTL = ttl
+	}
+	if v := getenv(envPrefix + "GAME_EXPIRY"); v != "" {
+		cfg.GameExpiry = game.GameExpiry(v)
+	}
+	if v := getenv(envPrefix + "JANITOR_PERIOD"); v != "" {
+		period, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sJANITOR_PERIOD: %w", envPrefix, err)
+		}
+		cfg.JanitorPeriod = period
+	}
+	if v := getenv(envPrefix + "METRICS_ADDR"); v != "" {
+		cfg.MetricsAddr = v
+	}
+	return nil
+}
+
+// splitList splits comma separated values dropping empty ones.
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(nil, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, game.DefaultConfig(), cfg)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "gpoker.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+addr: ":9000"
+corsOrigins: ["http://file.example.com"]
+shutdownTimeout: 10s
+ginMode: release
+storage:
+  backend: file
+  path: /var/lib/gpoker/file.log
+webhooks:
+  global:
+    - url: https://hooks.example.com/gpoker
+      secret: s3cret
+      events: [game_created, estimate_finalized]
+  backoff: 2s
+`), 0o600))
+	fileWebhooks := game.DefaultConfig().Webhooks
+	fileWebhooks.Global = []game.WebhookTarget{{
+		URL:    "https://hooks.example.com/gpoker",
+		Secret: "s3cret",
+		Events: []game.EventType{game.EventGameCreated, game.EventEstimateFinalized},
+	}}
+	fileWebhooks.Backoff = 2 * time.Second
+	jsonPath := filepath.Join(dir, "gpoker.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"addr": ":9001", "wsCheckOrigin": true}`), 0o600))
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(cfg *game.Config)
+	}{
+		{
+			name: "yaml file",
+			args: []string{"-config", yamlPath},
+			expected: func(cfg *game.Config) {
+				cfg.Addr = ":9000"
+				cfg.CORSOrigins = []string{"http://file.example.com"}
+				cfg.ShutdownTimeout = 10 * time.Second
+				cfg.GinMode = "release"
+				cfg.Storage = game.StorageConfig{Backend: game.StorageFile, Path: "/var/lib/gpoker/file.log"}
+				cfg.Webhooks = fileWebhooks
+			},
+		},
+		{
+			name: "json file from env",
+			env:  map[string]string{"GPOKER_CONFIG": jsonPath},
+			expected: func(cfg *game.Config) {
+				cfg.Addr = ":9001"
+				cfg.WSCheckOrigin = true
+			},
+		},
+		{
+			name: "env overrides file",
+			args: []string{"-config", yamlPath},
+			env: map[string]string{
+				"GPOKER_ADDR":             ":9002",
+				"GPOKER_CORS_ORIGINS":     "http://a.example.com, http://b.example.com",
+				"GPOKER_WS_CHECK_ORIGIN":  "true",
+				"GPOKER_SHUTDOWN_TIMEOUT": "1m",
+				"GPOKER_STORAGE_PATH":     "/tmp/env.log",
+				"GPOKER_AUTH_SECRET":      "s3cret",
+				"GPOKER_ADMIN_TOKEN":      "adm1n",
+				"GPOKER_SESSION_TTL":      "1h",
+				"GPOKER_METRICS_ADDR":     "localhost:9100",
+				"GPOKER_LOG_LEVEL":        "debug",
+				"GPOKER_LOG_FORMAT":       "json",
+				"GPOKER_GAME_TTL":         "48h",
+				"GPOKER_GAME_EXPIRY":      "delete",
+				"GPOKER_JANITOR_PERIOD":   "5m",
+				"GPOKER_WS_PING_PERIOD":   "10s",
+			},
+			expected: func(cfg *game.Config) {
+				cfg.Addr = ":9002"
+				cfg.CORSOrigins = []string{"http://a.example.com", "http://b.example.com"}
+				cfg.WSCheckOrigin = true
+				cfg.ShutdownTimeout = time.Minute
+				cfg.GinMode = "release"
+				cfg.Storage = game.StorageConfig{Backend: game.StorageFile, Path: "/tmp/env.log"}
+				cfg.AuthSecret = "s3cret"
+				cfg.AdminToken = "adm1n"
+				cfg.SessionTTL = time.Hour
+				cfg.MetricsAddr = "localhost:9100"
+				cfg.LogLevel = "debug"
+				cfg.LogFormat = game.LogFormatJSON
+				cfg.GameTTL = 48 * time.Hour
+				cfg.GameExpiry = game.GameExpiryDelete
+				cfg.JanitorPeriod = 5 * time.Minute
+				cfg.WSPingPeriod = 10 * time.Second
+				cfg.Webhooks = fileWebhooks
+			},
+		},
+		{
+			name: "flags override env and file",
+			args: []string{
+				"-config", yamlPath,
+				"-addr", ":9003",
+				"-gin-mode", "test",
+				"-storage", "memory",
+				"-ws-check-origin=false",
+				"-session-ttl", "30m",
+				"-metrics-addr", ":9101",
+				"-log-level", "warn",
+				"-game-ttl", "0",
+				"-game-expiry", "archive",
+				"-ws-ping-period", "1m",
+			},
+			env: map[string]string{
+				"GPOKER_ADDR":            ":9002",
+				"GPOKER_WS_CHECK_ORIGIN": "true",
+				"GPOKER_SESSION_TTL":     "1h",
+				"GPOKER_METRICS_ADDR":    "localhost:9100",
+				"GPOKER_LOG_LEVEL":       "debug",
+				"GPOKER_GAME_TTL":        "48h",
+				"GPOKER_GAME_EXPIRY":     "delete",
+			},
+			expected: func(cfg *game.Config) {
+				cfg.Addr = ":9003"
+				cfg.CORSOrigins = []string{"http://file.example.com"}
+				cfg.ShutdownTimeout = 10 * time.Second
+				cfg.GinMode = "test"
+				cfg.Storage = game.StorageConfig{Backend: game.StorageMemory, Path: "/var/lib/gpoker/file.log"}
+				cfg.SessionTTL = 30 * time.Minute
+				cfg.MetricsAddr = ":9101"
+				cfg.LogLevel = "warn"
+				cfg.GameTTL = 0
+				cfg.WSPingPeriod = time.Minute
+				cfg.Webhooks = fileWebhooks
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected := game.DefaultConfig()
+			test.expected(&expected)
+			cfg, err := loadConfig(test.args, env(test.env))
+			require.NoError(t, err)
+			require.Equal(t, expected, cfg)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknownField := filepath.Join(dir, "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknownField, []byte("port: 8080\n"), 0o600))
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "unknown flag", args: []string{"-port", "8080"}},
+		{name: "missing file", args: []string{"-config", filepath.Join(dir, "missing.yaml")}},
+		{name: "unknown field in file", args: []string{"-config", unknownField}},
+		{name: "bad duration", env: map[string]string{"GPOKER_SHUTDOWN_TIMEOUT": "soon"}},
+		{name: "bad session ttl", env: map[string]string{"GPOKER_SESSION_TTL": "1 day"}},
+		{name: "bad game ttl", env: map[string]string{"GPOKER_GAME_TTL": "a month"}},
+		{name: "bad ping period", env: map[string]string{"GPOKER_WS_PING_PERIOD": "often"}},
+		{name: "bad bool", env: map[string]string{"GPOKER_WS_CHECK_ORIGIN": "maybe"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadConfig(test.args, env(test.env))
+			require.Error(t, err)
+		})
+	}
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
@@ -2,22 +2,96 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"gpoker/pkg/game"
-	"log"
+	"io"
+	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 )
 
+const usage = `Usage: gpoker <command> [flags] [arguments]
+
+Commands:
+  serve                         start the server, the default if no command is given
+  signup NAME                   register a player and print its session token
+  games list [flags]            list games, a page at a time
+  games create [flags] NAME     create a game
+  games show GAME_ID            show a game with its players
+  games export [flags] GAME_ID  export results of a game as CSV, JSON or Markdown
+  join [flags] GAME_ID|CODE     join a game by its ID or join code
+  vote GAME_ID CARD             vote in the current round of a game
+  watch GAME_ID                 show live updates of a game
+
+Client commands call the server set by -server flag or GPOKER_SERVER variable and authenticate with the session
+token set by -token flag or GPOKER_TOKEN variable. Run "gpoker <command> -h" to see flags of a command.
+`
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
-	srv := game.NewServer()
-	srv.Start()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
+
+// run executes a command given by args and returns the exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	cmd := cli{stdout: stdout, stderr: stderr, getenv: getenv}
+	var err error
+	switch command {
+	case "serve":
+		err = serve(ctx, args, getenv)
+	case "signup":
+		err = cmd.signup(ctx, args)
+	case "games":
+		err = cmd.games(ctx, args)
+	case "join":
+		err = cmd.join(ctx, args)
+	case "vote":
+		err = cmd.vote(ctx, args)
+	case "watch":
+		err = cmd.watch(ctx, args)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "gpoker %s: %s\n", command, err)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "gpoker %s: %s\n", command, err)
+		return 1
+	}
+}
+
+// serve runs the server until ctx is done.
+func serve(ctx context.Context, args []string, getenv func(string) string) error {
+	cfg, err := loadConfig(args, getenv)
+	if err != nil {
+		return usageError{fmt.Errorf("failed to load configuration: %w", err)}
+	}
+	srv, err := game.NewStartedServer(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to start the server: %w", err)
+	}
 	<-ctx.Done()
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Stop(ctx); err != nil {
-		log.Printf("Error shutting down the server = %s", err)
+		srv.Logger().WithError(err).Error("Failed to shut down the server")
 	}
+	return nil
 }
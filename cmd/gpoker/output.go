@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gpoker/pkg/game"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printJSONLine prints v as a single line of JSON, so a stream of values can be processed line by line.
+func printJSONLine(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+func printSignup(w io.Writer, signup game.SignupResponse) error {
+	table := newTable(w)
+	fmt.Fprintf(table, "ID\t%d\n", signup.ID)
+	fmt.Fprintf(table, "NAME\t%s\n", signup.Name)
+	fmt.Fprintf(table, "TOKEN\t%s\n", signup.Token)
+	fmt.Fprintf(table, "EXPIRES\t%s\n", signup.ExpiresAt.Local().Format(time.RFC1123))
+	return table.Flush()
+}
+
+// printGames prints a table of games and the cursor of the next page, if there is one.
+func printGames(w io.Writer, games game.GameListResponse) error {
+	table := newTable(w)
+	fmt.Fprintln(table, "ID\tNAME\tPLAYERS\tSTATE")
+	for _, entry := range games.Games {
+		state := string(entry.State)
+		if entry.Archived {
+			state = "archived"
+		}
+		fmt.Fprintf(table, "%d\t%s\t%d\t%s\n", entry.ID, entry.Name, entry.PlayerCount, state)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if games.NextCursor != "" {
+		_, err := fmt.Fprintf(w, "More games: -cursor %s\n", games.NextCursor)
+		return err
+	}
+	return nil
+}
+
+// printGame prints a summary of the game, the current story and a table of players with their votes.
+func printGame(w io.Writer, poker game.GameResponse) error {
+	fmt.Fprintf(w, "Game %d %q, %s, %s deck: %s\n",
+		poker.ID, poker.Name, poker.State, poker.Deck.Type, joinVotes(poker.Deck.Cards))
+	if poker.Code != "" { // only players of the game see it
+		fmt.Fprintf(w, "Join code: %s\n", poker.Code)
+	}
+	if poker.Timer != nil {
+		fmt.Fprintf(w, "Timer: %s left, %s on expiry\n",
+			formatRemaining(time.Until(poker.Timer.Deadline).Seconds()), poker.Timer.OnExpiry)
+	}
+	for _, story := range poker.Stories {
+		if story.ID == poker.CurrentStoryID {
+			fmt.Fprintf(w, "Story: %s\n", strings.TrimSpace(story.Key+" "+story.Title))
+		}
+	}
+	table := newTable(w)
+	fmt.Fprintln(table, "PLAYER\tROLE\tVOTED\tVOTE")
+	for _, player := range poker.Players {
+		voted := "no"
+		if player.Voted {
+			voted = "yes"
+		}
+		vote := string(player.Vote)
+		if vote == "" {
+			vote = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", player.Name, player.Role, voted, vote)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if poker.Stats != nil {
+		return printStats(w, *poker.Stats)
+	}
+	return nil
+}
+
+func printStats(w io.Writer, stats game.RoundStats) error {
+	parts := []string{fmt.Sprintf("votes %d", stats.Votes)}
+	if stats.Average != nil {
+		parts = append(parts, fmt.Sprintf("average %.1f", *stats.Average))
+	}
+	if stats.Median != nil {
+		parts = append(parts, fmt.Sprintf("median %.1f", *stats.Median))
+	}
+	if len(stats.Mode) > 0 {
+		parts = append(parts, "mode "+joinVotes(stats.Mode))
+	}
+	if stats.Consensus {
+		parts = append(parts, "consensus")
+	}
+	_, err := fmt.Fprintf(w, "Stats: %s\n", strings.Join(parts, ", "))
+	return err
+}
+
+func joinVotes(votes []game.Vote) string {
+	cards := make([]string, 0, len(votes))
+	for _, vote := range votes {
+		cards = append(cards, string(vote))
+	}
+	return strings.Join(cards, " ")
+}
+
+// formatRemaining formats seconds left of a timer as minutes and seconds, e.g. "1:05".
+func formatRemaining(seconds float64) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	total := int(math.Ceil(seconds))
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"gpoker/pkg/game"
+	"sort"
+)
+
+// watchedGame is the state of a game kept up to date from its events, so watching a game doesn't need a request for
+// every event. It starts from game.EventGameSnapshot.
+type watchedGame struct {
+	game.GameResponse
+	ended bool // the game was deleted or expired, no more events will come
+}
+
+// apply updates the game with an event. Events that don't change the game, like timer ticks, are ignored.
+func (w *watchedGame) apply(event game.Event) error {
+	switch event.Type {
+	case game.EventGameSnapshot:
+		return json.Unmarshal(event.Payload, &w.GameResponse)
+	case game.EventGameDeleted, game.EventGameExpired:
+		w.ended = true
+	case game.EventPlayerJoined:
+		var player game.PlayerResponse
+		if err := json.Unmarshal(event.Payload, &player); err != nil {
+			return err
+		}
+		w.removePlayer(player.ID)
+		w.addPlayer(player)
+	case game.EventPlayerLeft:
+		var left game.PlayerLeft
+		if err := json.Unmarshal(event.Payload, &left); err != nil {
+			return err
+		}
+		w.removePlayer(left.PlayerID)
+	case game.EventRolesChanged:
+		var changed game.RolesChanged
+		if err := json.Unmarshal(event.Payload, &changed); err != nil {
+			return err
+		}
+		for _, player := range changed.Players {
+			w.updatePlayer(player.ID, func(p *game.PlayerResponse) { p.Role = player.Role })
+		}
+	case game.EventPresenceChanged:
+		var presence game.PresenceChanged
+		if err := json.Unmarshal(event.Payload, &presence); err != nil {
+			return err
+		}
+		w.updatePlayer(presence.PlayerID, func(p *game.PlayerResponse) {
+			p.Online = presence.Online
+			p.LastSeen = &presence.LastSeen
+		})
+	case game.EventVoteCast:
+		var cast game.VoteCast
+		if err := json.Unmarshal(event.Payload, &cast); err != nil {
+			return err
+		}
+		w.updatePlayer(cast.PlayerID, func(p *game.PlayerResponse) { p.Voted = true })
+	case game.EventRoundRevealed:
+		code := w.Code // events don't have the join code
+		if err := json.Unmarshal(event.Payload, &w.GameResponse); err != nil {
+			return err
+		}
+		w.Code = code
+	case game.EventRoundStarted:
+		w.newRound()
+	case game.EventVotingClosed:
+		w.State = game.RoundClosed
+	case game.EventGameRenamed:
+		var renamed game.GameRenamed
+		if err := json.Unmarshal(event.Payload, &renamed); err != nil {
+			return err
+		}
+		w.Name = renamed.Name
+	case game.EventSettingsChanged:
+		var changed game.SettingsChanged
+		if err := json.Unmarshal(event.Payload, &changed); err != nil {
+			return err
+		}
+		w.Settings = changed.Settings
+	case game.EventTimerStarted:
+		var timer game.TimerEvent
+		if err := json.Unmarshal(event.Payload, &timer); err != nil {
+			return err
+		}
+		w.Timer = &timer.RoundTimer
+	case game.EventTimerExpired, game.EventTimerCancelled:
+		w.Timer = nil
+	default:
+		return w.applyStoryEvent(event)
+	}
+	return nil
+}
+
+// applyStoryEvent updates the backlog of the game with an event of its stories.
+func (w *watchedGame) applyStoryEvent(event game.Event) error {
+	switch event.Type {
+	case game.EventStoryAdded:
+		var story game.Story
+		if err := json.Unmarshal(event.Payload, &story); err != nil {
+			return err
+		}
+		w.Stories = append(w.Stories, game.StoryResponse{Story: story, Rounds: []game.Round{}})
+	case game.EventStoryRemoved:
+		var removed game.StoryRef
+		if err := json.Unmarshal(event.Payload, &removed); err != nil {
+			return err
+		}
+		for i, story := range w.Stories {
+			if story.ID == removed.StoryID {
+				w.Stories = append(w.Stories[:i], w.Stories[i+1:]...)
+				break
+			}
+		}
+	case game.EventStoriesReordered:
+		var order []game.StoryID
+		if err := json.Unmarshal(event.Payload, &order); err != nil {
+			return err
+		}
+		byID := make(map[game.StoryID]game.StoryResponse, len(w.Stories))
+		for _, story := range w.Stories {
+			byID[story.ID] = story
+		}
+		w.Stories = w.Stories[:0]
+		for _, id := range order {
+			w.Stories = append(w.Stories, byID[id])
+		}
+	case game.EventStoriesImported:
+		var imported game.StoriesImported
+		if err := json.Unmarshal(event.Payload, &imported); err != nil {
+			return err
+		}
+		if imported.Mode == game.ImportReplace {
+			w.Stories = nil
+		}
+		for _, story := range imported.Stories {
+			w.Stories = append(w.Stories, game.StoryResponse{Story: story, Rounds: []game.Round{}})
+		}
+	case game.EventStoryStarted: // starting a story starts a new round
+		var started game.StoryRef
+		if err := json.Unmarshal(event.Payload, &started); err != nil {
+			return err
+		}
+		w.CurrentStoryID = started.StoryID
+		w.newRound()
+	case game.EventEstimateFinalized:
+		var estimated game.Story
+		if err := json.Unmarshal(event.Payload, &estimated); err != nil {
+			return err
+		}
+		for i := range w.Stories {
+			if w.Stories[i].ID == estimated.ID {
+				w.Stories[i].Story = estimated
+			}
+		}
+	}
+	return nil // events of a newer server are printed, but don't change the game
+}
+
+func (w *watchedGame) newRound() {
+	w.State = game.RoundVoting
+	w.Stats = nil
+	for i := range w.Players {
+		w.Players[i].Voted = false
+		w.Players[i].Vote = ""
+	}
+}
+
+// addPlayer inserts the player where the snapshot has it: by name, then by ID, as names are not unique.
+func (w *watchedGame) addPlayer(player game.PlayerResponse) {
+	i := sort.Search(len(w.Players), func(i int) bool {
+		if w.Players[i].Name != player.Name {
+			return w.Players[i].Name > player.Name
+		}
+		return w.Players[i].ID > player.ID
+	})
+	w.Players = append(w.Players, game.PlayerResponse{})
+	copy(w.Players[i+1:], w.Players[i:])
+	w.Players[i] = player
+}
+
+func (w *watchedGame) removePlayer(id game.PlayerID) {
+	for i, player := range w.Players {
+		if player.ID == id {
+			w.Players = append(w.Players[:i], w.Players[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *watchedGame) updatePlayer(id game.PlayerID, update func(*game.PlayerResponse)) {
+	for i := range w.Players {
+		if w.Players[i].ID == id {
+			update(&w.Players[i])
+		}
+	}
+}
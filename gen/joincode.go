@@ -0,0 +1,57 @@
+package gen
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// joinCodeAlphabet has no characters that are easy to confuse when read or said aloud: 0 and O, 1, I and L.
+const joinCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+const (
+	joinCodeGroups      = 2
+	joinCodeGroupLength = 3
+	joinCodeSeparator   = '-'
+)
+
+// JoinCode returns a random code like "QX7-MP4" that is easy to share. It's generated with crypto/rand, so codes
+// can't be guessed from each other.
+func JoinCode() (string, error) {
+	var sb strings.Builder
+	sb.Grow(joinCodeGroups*(joinCodeGroupLength+1) - 1)
+	max := big.NewInt(int64(len(joinCodeAlphabet)))
+	for i := 0; i < joinCodeGroups*joinCodeGroupLength; i++ {
+		if i > 0 && i%joinCodeGroupLength == 0 {
+			sb.WriteByte(joinCodeSeparator)
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(joinCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// NormalizeJoinCode turns a code as a person would type it, e.g. "qx7 mp4", into the form JoinCode returns. The
+// result is not a valid code if the input isn't one.
+func NormalizeJoinCode(code string) string {
+	var chars []byte
+	for _, c := range []byte(strings.ToUpper(code)) {
+		if c != joinCodeSeparator && c != ' ' {
+			chars = append(chars, c)
+		}
+	}
+	if len(chars) != joinCodeGroups*joinCodeGroupLength {
+		return string(chars)
+	}
+	var sb strings.Builder
+	for i, c := range chars {
+		if i > 0 && i%joinCodeGroupLength == 0 {
+			sb.WriteByte(joinCodeSeparator)
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,29 @@
+package gen_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/gen"
+	"testing"
+)
+
+func TestJoinCode(t *testing.T) {
+	codes := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := gen.JoinCode()
+		require.NoError(t, err)
+		require.Regexp(t, `^[2-9A-HJKMNP-Z]{3}-[2-9A-HJKMNP-Z]{3}$`, code)
+		codes[code] = true
+	}
+	require.Greater(t, len(codes), 90) // not a fixed sequence
+}
+
+func TestNormalizeJoinCode(t *testing.T) {
+	for input, expected := range map[string]string{
+		"QX7-MP4":  "QX7-MP4",
+		"qx7mp4":   "QX7-MP4",
+		" qx7 mp4": "QX7-MP4",
+		"qx7-mp":   "QX7MP",
+	} {
+		require.Equal(t, expected, gen.NormalizeJoinCode(input), input)
+	}
+}
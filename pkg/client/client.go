@@ -0,0 +1,326 @@
+// Package client is a Go client of the gpoker server API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpoker/pkg/game"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxErrorMessage limits how much of an error response body ends up in Error.Message.
+const maxErrorMessage = 1024
+
+// Client calls a gpoker server. Requests that change games need a session token, see WithToken. A Client is safe for
+// concurrent use.
+type Client struct {
+	baseURL    string // without a trailing slash
+	httpClient *http.Client
+	token      string
+}
+
+// New creates a Client of a server at baseURL, e.g. "http://localhost:8080". http.DefaultClient is used if
+// httpClient is nil.
+func New(baseURL string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q must be http or https", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(u.String(), "/"),
+		httpClient: httpClient,
+	}, nil
+}
+
+// WithToken returns a copy of the client that authenticates with a session token, e.g. the one from Signup.
+func (c *Client) WithToken(token string) *Client {
+	authenticated := *c
+	authenticated.token = token
+	return &authenticated
+}
+
+// Token returns the session token of the client, empty if it's not authenticated.
+func (c *Client) Token() string {
+	return c.token
+}
+
+// Health checks that the server is up.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil)
+}
+
+// Signup registers a new player. Use WithToken with the returned token to act as this player.
+func (c *Client) Signup(ctx context.Context, name string) (game.SignupResponse, error) {
+	var resp game.SignupResponse
+	err := c.do(ctx, http.MethodPost, "/api/signup", game.RegisterUserRequest{Name: name}, &resp)
+	return resp, err
+}
+
+// Logout revokes the session token of the client.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/logout", nil, nil)
+}
+
+// CreateGame starts a new game with the authenticated player as its facilitator.
+func (c *Client) CreateGame(ctx context.Context, req game.CreatePokerRequest) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPost, "/api/games", req, &resp)
+	return resp, err
+}
+
+// ListGames returns a page of games matching req. The zero request lists the first page of active games sorted by
+// name. Pass NextCursor of the response as req.Cursor to get the next page.
+func (c *Client) ListGames(ctx context.Context, req game.ListGamesRequest) (game.GameListResponse, error) {
+	var resp game.GameListResponse
+	err := c.do(ctx, http.MethodGet, "/api/games"+listGamesQuery(req), nil, &resp)
+	return resp, err
+}
+
+// GetGame returns the current state of a game.
+func (c *Client) GetGame(ctx context.Context, gameID game.GameID) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodGet, gamePath(gameID, ""), nil, &resp)
+	return resp, err
+}
+
+// ExportGame writes the results of a game to w in the given format, see game.ExportFormat.
+func (c *Client) ExportGame(ctx context.Context, gameID game.GameID, format game.ExportFormat, w io.Writer) error {
+	path := gamePath(gameID, "/export?"+url.Values{"format": {string(format)}}.Encode())
+	return c.do(ctx, http.MethodGet, path, nil, w)
+}
+
+// ImportStories adds stories to the backlog of a game or, in game.ImportReplace mode, replaces the backlog with them.
+// Only the facilitator can import stories.
+func (c *Client) ImportStories(ctx context.Context, gameID game.GameID, mode game.ImportMode, stories []game.StoryRequest) (game.GameResponse, error) {
+	path := gamePath(gameID, "/stories/import")
+	if mode != "" {
+		path += "?" + url.Values{"mode": {string(mode)}}.Encode()
+	}
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPost, path, stories, &resp)
+	return resp, err
+}
+
+// DeleteGame deletes a game. Only the facilitator can delete it.
+func (c *Client) DeleteGame(ctx context.Context, gameID game.GameID) error {
+	return c.do(ctx, http.MethodDelete, gamePath(gameID, ""), nil, nil)
+}
+
+// JoinGame adds the authenticated player to a game. Role is game.RoleVoter if it's empty.
+func (c *Client) JoinGame(ctx context.Context, gameID game.GameID, role game.Role) error {
+	return c.do(ctx, http.MethodPut, gamePath(gameID, "/join"), game.JoinPokerRequest{Role: role}, nil)
+}
+
+// GameByCode returns the game with the join code.
+func (c *Client) GameByCode(ctx context.Context, code string) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodGet, "/api/join/"+url.PathEscape(code), nil, &resp)
+	return resp, err
+}
+
+// JoinGameByCode is like JoinGame, but finds the game by its join code. It returns the game joined.
+func (c *Client) JoinGameByCode(ctx context.Context, code string, role game.Role) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPut, "/api/join/"+url.PathEscape(code), game.JoinPokerRequest{Role: role}, &resp)
+	return resp, err
+}
+
+// LeaveGame removes the authenticated player from a game.
+func (c *Client) LeaveGame(ctx context.Context, gameID game.GameID) error {
+	return c.do(ctx, http.MethodDelete, gamePath(gameID, "/join"), nil, nil)
+}
+
+// Vote casts the authenticated player's vote in the current round of a game.
+func (c *Client) Vote(ctx context.Context, gameID game.GameID, vote game.Vote) error {
+	return c.do(ctx, http.MethodPost, gamePath(gameID, "/vote"), game.VoteRequest{Vote: vote}, nil)
+}
+
+// UpdateSettings changes the settings of a game that are set in req. Only the facilitator can change them.
+func (c *Client) UpdateSettings(ctx context.Context, gameID game.GameID, req game.GameSettingsRequest) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPatch, gamePath(gameID, "/settings"), req, &resp)
+	return resp, err
+}
+
+// StartTimer starts a countdown of the current round, replacing a running one. Only the facilitator can start it.
+func (c *Client) StartTimer(ctx context.Context, gameID game.GameID, req game.TimerRequest) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPost, gamePath(gameID, "/timer"), req, &resp)
+	return resp, err
+}
+
+// StopTimer cancels the countdown of the current round. Only the facilitator can stop it.
+func (c *Client) StopTimer(ctx context.Context, gameID game.GameID) error {
+	return c.do(ctx, http.MethodDelete, gamePath(gameID, "/timer"), nil, nil)
+}
+
+// Reveal shows votes of the current round. Only the facilitator can reveal.
+func (c *Client) Reveal(ctx context.Context, gameID game.GameID) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPost, gamePath(gameID, "/reveal"), nil, &resp)
+	return resp, err
+}
+
+// NewRound drops all votes and starts voting again. Only the facilitator can start a round.
+func (c *Client) NewRound(ctx context.Context, gameID game.GameID) (game.GameResponse, error) {
+	var resp game.GameResponse
+	err := c.do(ctx, http.MethodPost, gamePath(gameID, "/round"), nil, &resp)
+	return resp, err
+}
+
+// listGamesQuery encodes the set fields of req as a query string, including the leading "?".
+func listGamesQuery(req game.ListGamesRequest) string {
+	query := url.Values{}
+	if req.Name != "" {
+		query.Set("name", req.Name)
+	}
+	if req.CreatedBy != 0 {
+		query.Set("createdBy", strconv.FormatUint(uint64(req.CreatedBy), 10))
+	}
+	if req.Member != 0 {
+		query.Set("member", strconv.FormatUint(uint64(req.Member), 10))
+	}
+	if req.Status != "" {
+		query.Set("status", string(req.Status))
+	}
+	if req.Sort != "" {
+		query.Set("sort", string(req.Sort))
+	}
+	if req.Limit != 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	if req.Cursor != "" {
+		query.Set("cursor", req.Cursor)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func gamePath(gameID game.GameID, suffix string) string {
+	return fmt.Sprintf("/api/games/%d%s", gameID, suffix)
+}
+
+// do sends body encoded as JSON and decodes the response into out. Both body and out can be nil. If out is an
+// io.Writer, the response is copied into it as is.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if w, ok := out.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("not authenticated")
+	ErrForbidden    = errors.New("not allowed")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrServer       = errors.New("server error")
+)
+
+// Error is returned when the server responds with an error status. It matches one of the Err* variables with
+// errors.Is depending on the status code.
+type Error struct {
+	StatusCode int
+	Code       game.ErrorCode    // empty if the response is not a game.ErrorResponse, e.g. from a proxy
+	Message    string            // message of the server or body of the response, if any
+	Details    []game.FieldError // problems with fields of the request
+	RequestID  string            // ID of the request on the server, useful to find it in the logs
+}
+
+func newError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessage))
+	var errResp game.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Code == "" {
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       errResp.Code,
+		Message:    errResp.Message,
+		Details:    errResp.Details,
+		RequestID:  errResp.RequestID,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gpoker: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	message := e.Message
+	for _, detail := range e.Details {
+		message += fmt.Sprintf("; %s %s", detail.Field, detail.Message)
+	}
+	return fmt.Sprintf("gpoker: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), message)
+}
+
+// Is reports whether the status code corresponds to target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
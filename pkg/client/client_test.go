@@ -0,0 +1,236 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/client"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// The server listens on its own port, so these tests can run in parallel with tests of other packages.
+const testAddr = "localhost:8081"
+
+func TestClient(t *testing.T) {
+	anonymous := startServer(t)
+	ctx := context.Background()
+
+	signup, err := anonymous.Signup(ctx, "bobby")
+	require.NoError(t, err)
+	require.Equal(t, "bobby", signup.Name)
+	bobby := anonymous.WithToken(signup.Token)
+	require.Equal(t, signup.Token, bobby.Token())
+	require.Empty(t, anonymous.Token())
+
+	created, err := bobby.CreateGame(ctx, game.CreatePokerRequest{GameName: "sprint", Deck: game.DeckTShirt})
+	require.NoError(t, err)
+	require.Equal(t, game.DeckTShirt, created.Deck.Type)
+	_, err = bobby.CreateGame(ctx, game.CreatePokerRequest{GameName: "retro"})
+	require.NoError(t, err)
+	games, err := anonymous.ListGames(ctx, game.ListGamesRequest{Name: "SPR", CreatedBy: signup.ID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, games.Games, 1)
+	require.Equal(t, created.ID, games.Games[0].ID)
+	require.Equal(t, "sprint", games.Games[0].Name)
+	require.Empty(t, games.NextCursor)
+	games, err = anonymous.ListGames(ctx, game.ListGamesRequest{Sort: "-created", Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, "retro", games.Games[0].Name)
+	require.NotEmpty(t, games.NextCursor)
+	games, err = anonymous.ListGames(ctx, game.ListGamesRequest{Sort: "-created", Cursor: games.NextCursor})
+	require.NoError(t, err)
+	require.Equal(t, "sprint", games.Games[0].Name)
+
+	alice := signupAs(t, anonymous, "alice")
+	require.NoError(t, alice.JoinGame(ctx, created.ID, ""))
+	require.NoError(t, alice.Vote(ctx, created.ID, "M"))
+	require.NoError(t, bobby.Vote(ctx, created.ID, "L"))
+	poker, err := anonymous.GetGame(ctx, created.ID)
+	require.NoError(t, err)
+	require.Len(t, poker.Players, 2)
+	require.Equal(t, game.RoundVoting, poker.State)
+
+	poker, err = bobby.Reveal(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, poker.Stats.Votes)
+	poker, err = bobby.NewRound(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, game.RoundVoting, poker.State)
+	autoReveal := true
+	poker, err = bobby.UpdateSettings(ctx, created.ID, game.GameSettingsRequest{AutoReveal: &autoReveal})
+	require.NoError(t, err)
+	require.True(t, poker.Settings.AutoReveal)
+	poker, err = bobby.ImportStories(ctx, created.ID, game.ImportReplace, []game.StoryRequest{
+		{Key: "PRJ-1", Title: "Login"},
+		{Key: "PRJ-2", Title: "Logout", Link: "https://tracker.example.com/PRJ-2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, poker.Stories, 2)
+	require.Equal(t, poker.Stories[0].ID, poker.CurrentStoryID)
+	_, err = bobby.ImportStories(ctx, created.ID, "", []game.StoryRequest{{Key: "PRJ-3"}})
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, []game.FieldError{{Field: "rows[1].title", Message: "is required"}}, apiErr.Details)
+
+	found, err := anonymous.GameByCode(ctx, created.Code)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, found.ID)
+	carol := signupAs(t, anonymous, "carol")
+	joined, err := carol.JoinGameByCode(ctx, created.Code, game.RoleObserver)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, joined.ID)
+	require.Len(t, joined.Players, 3)
+	_, err = anonymous.GameByCode(ctx, "AAA-AAA")
+	require.ErrorIs(t, err, client.ErrNotFound)
+
+	require.NoError(t, alice.LeaveGame(ctx, created.ID))
+	require.NoError(t, alice.Logout(ctx))
+	require.ErrorIs(t, alice.Vote(ctx, created.ID, "M"), client.ErrUnauthorized)
+}
+
+func TestClientErrors(t *testing.T) {
+	anonymous := startServer(t)
+	ctx := context.Background()
+	bobby := signupAs(t, anonymous, "bobby")
+	created, err := bobby.CreateGame(ctx, game.CreatePokerRequest{GameName: "sprint"})
+	require.NoError(t, err)
+	alice := signupAs(t, anonymous, "alice")
+	require.NoError(t, alice.JoinGame(ctx, created.ID, game.RoleObserver))
+
+	_, err = anonymous.Signup(ctx, "")
+	require.ErrorIs(t, err, client.ErrBadRequest)
+	require.Contains(t, err.Error(), "name is required")
+	_, err = anonymous.CreateGame(ctx, game.CreatePokerRequest{GameName: "sprint"})
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	_, err = anonymous.GetGame(ctx, 100)
+	require.ErrorIs(t, err, client.ErrNotFound)
+	require.ErrorIs(t, alice.Vote(ctx, created.ID, "1"), client.ErrForbidden)
+	_, err = bobby.Reveal(ctx, created.ID)
+	require.NoError(t, err)
+	require.ErrorIs(t, bobby.Vote(ctx, created.ID, "1"), client.ErrConflict)
+
+	err = bobby.Vote(ctx, created.ID, "1")
+	var clientErr *client.Error
+	require.True(t, errors.As(err, &clientErr))
+	require.Equal(t, http.StatusConflict, clientErr.StatusCode)
+	require.Equal(t, game.CodeVotingClosed, clientErr.Code)
+	require.NotEmpty(t, clientErr.RequestID)
+	require.False(t, errors.Is(err, client.ErrNotFound))
+}
+
+func TestClientContextCancel(t *testing.T) {
+	anonymous := startServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := anonymous.ListGames(ctx, game.ListGamesRequest{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewClientErrors(t *testing.T) {
+	for _, baseURL := range []string{"localhost:8080", "ftp://localhost", "://"} {
+		_, err := client.New(baseURL, nil)
+		require.Error(t, err, baseURL)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	anonymous := startServer(t)
+	ctx := context.Background()
+	bobby := signupAs(t, anonymous, "bobby")
+	created, err := bobby.CreateGame(ctx, game.CreatePokerRequest{GameName: "sprint"})
+	require.NoError(t, err)
+
+	sub, err := anonymous.Subscribe(ctx, created.ID)
+	require.NoError(t, err)
+	defer sub.Close()
+	snapshot := nextEvent(t, sub)
+	require.Equal(t, game.EventGameSnapshot, snapshot.Type)
+	require.Equal(t, created.ID, snapshot.GameID)
+
+	require.NoError(t, bobby.Vote(ctx, created.ID, "3"))
+	require.Equal(t, game.EventVoteCast, nextEvent(t, sub).Type)
+
+	sub.Close()
+	sub.Close() // second time is a no-op
+	for range sub.Events() {
+	}
+	require.NoError(t, sub.Err())
+
+	_, err = anonymous.Subscribe(ctx, 100)
+	require.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestSubscribeEndsWithContext(t *testing.T) {
+	anonymous := startServer(t)
+	bobby := signupAs(t, anonymous, "bobby")
+	created, err := bobby.CreateGame(context.Background(), game.CreatePokerRequest{GameName: "sprint"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := anonymous.Subscribe(ctx, created.ID)
+	require.NoError(t, err)
+	nextEvent(t, sub) // snapshot
+	cancel()
+	for range sub.Events() {
+	}
+	require.ErrorIs(t, sub.Err(), context.Canceled)
+}
+
+func TestSubscribeEndsWithGame(t *testing.T) {
+	anonymous := startServer(t)
+	ctx := context.Background()
+	bobby := signupAs(t, anonymous, "bobby")
+	created, err := bobby.CreateGame(ctx, game.CreatePokerRequest{GameName: "sprint"})
+	require.NoError(t, err)
+	sub, err := anonymous.Subscribe(ctx, created.ID)
+	require.NoError(t, err)
+	nextEvent(t, sub) // snapshot
+
+	require.NoError(t, bobby.DeleteGame(ctx, created.ID))
+
+	require.Equal(t, game.EventGameDeleted, nextEvent(t, sub).Type)
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+	require.NoError(t, sub.Err())
+}
+
+// startServer starts a server that is stopped at the end of the test and returns an unauthenticated client of it.
+func startServer(t *testing.T) *client.Client {
+	cfg := game.DefaultConfig()
+	cfg.Addr = testAddr
+	cfg.GinMode = "test"
+	srv, err := game.NewStartedServer(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, srv.Stop(context.Background())) })
+
+	c, err := client.New("http://"+testAddr, nil)
+	require.NoError(t, err)
+	deadline := time.Now().Add(4 * time.Second)
+	for err = c.Health(context.Background()); err != nil; err = c.Health(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server didn't start in time, last error %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return c
+}
+
+func signupAs(t *testing.T, c *client.Client, name string) *client.Client {
+	signup, err := c.Signup(context.Background(), name)
+	require.NoError(t, err)
+	return c.WithToken(signup.Token)
+}
+
+func nextEvent(t *testing.T, sub *client.Subscription) game.Event {
+	select {
+	case event, ok := <-sub.Events():
+		require.True(t, ok, "subscription ended: %v", sub.Err())
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event in time")
+		return game.Event{}
+	}
+}
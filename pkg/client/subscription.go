@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"gpoker/pkg/game"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// subscriptionBuffer is how many received events can wait for the reader of a Subscription.
+const subscriptionBuffer = 32
+
+// Subscription receives events of a game from the server's websocket.
+type Subscription struct {
+	conn   *websocket.Conn
+	events chan game.Event
+	err    error // why the subscription ended, set before events is closed
+
+	done      chan struct{} // closed when the subscription is closed from this side
+	closeErr  error         // why it was closed from this side, set before done is closed
+	closeOnce sync.Once
+}
+
+// Subscribe connects to the websocket of a game. The first event is always game.EventGameSnapshot with the current
+// state of the game. The subscription ends when ctx is done, Close is called or the server closes the connection,
+// e.g. because the game was deleted. An authenticated client is online in the game while subscribed.
+func (c *Client) Subscribe(ctx context.Context, gameID game.GameID) (*Subscription, error) {
+	wsURL := "ws" + strings.TrimPrefix(c.baseURL, "http") + fmt.Sprintf("/ws/games/%d", gameID)
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		Jar:              c.httpClient.Jar,
+	}
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	conn, resp, err := dialer.DialContext(ctx, wsURL, header)
+	if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+		defer resp.Body.Close()
+		return nil, newError(resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{
+		conn:   conn,
+		events: make(chan game.Event, subscriptionBuffer),
+		done:   make(chan struct{}),
+	}
+	go sub.read()
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.close(ctx.Err())
+		case <-sub.done:
+		}
+	}()
+	return sub, nil
+}
+
+// Events returns a channel of game events. It is closed when the subscription ends, Err tells why.
+func (s *Subscription) Events() <-chan game.Event {
+	return s.events
+}
+
+// Err returns why the subscription ended: nil after Close or if the server closed the connection normally, the error
+// of the context if it's done, or a connection error. It must be called only after Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close ends the subscription. It is safe to call it more than once.
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+func (s *Subscription) close(err error) {
+	s.closeOnce.Do(func() {
+		s.closeErr = err
+		close(s.done)
+		_ = s.conn.Close() // makes read return
+	})
+}
+
+func (s *Subscription) read() {
+	defer close(s.events)
+	for {
+		var event game.Event
+		if err := s.conn.ReadJSON(&event); err != nil {
+			select {
+			case <-s.done:
+				s.err = s.closeErr
+			default:
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					s.err = err
+				}
+				s.Close()
+			}
+			return
+		}
+		select {
+		case s.events <- event:
+		case <-s.done:
+			s.err = s.closeErr
+			return
+		}
+	}
+}
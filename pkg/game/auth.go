@@ -0,0 +1,241 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("session token is invalid, expired or revoked")
+var ErrNotAdmin = errors.New("admin token is required")
+
+const (
+	sessionIDLength = 16
+	bearerPrefix    = "Bearer "
+	playerKey       = "gpoker.player" // gin.Context key of the authenticated Player
+)
+
+// Sessions issues and verifies session tokens. A token is a random session ID signed with a secret, so it can't be
+// forged, while keeping sessions on the server makes them revocable. Sessions are saved to a SessionStore, so players
+// stay signed in after a restart as long as the secret stays the same.
+type Sessions struct {
+	secret   []byte
+	ttl      time.Duration
+	store    SessionStore
+	sessions map[string]session // by sessionKey of the session ID
+	lock     sync.Mutex         // protects sessions and writes to store
+}
+
+type session struct {
+	playerID  PlayerID
+	expiresAt time.Time
+}
+
+// NewSessions creates Sessions with tokens signed by secret that expire after ttl. A random secret is generated if
+// it's empty. Sessions are kept only in memory.
+func NewSessions(secret []byte, ttl time.Duration) (*Sessions, error) {
+	return NewSessionsWithStore(secret, ttl, MemoryStore{})
+}
+
+// NewSessionsWithStore is like NewSessions, but sessions are loaded from store and every change is saved to it. Tokens
+// of loaded sessions are valid only if they were signed with the same secret.
+func NewSessionsWithStore(secret []byte, ttl time.Duration, store SessionStore) (*Sessions, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, sha256.Size)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	saved, err := store.LoadSessions()
+	if err != nil {
+		return nil, err
+	}
+	sessions := &Sessions{
+		secret:   secret,
+		ttl:      ttl,
+		store:    store,
+		sessions: make(map[string]session, len(saved)),
+	}
+	now := time.Now()
+	for _, sess := range saved {
+		if now.Before(sess.ExpiresAt) {
+			sessions.sessions[sess.ID] = session{playerID: sess.PlayerID, expiresAt: sess.ExpiresAt}
+		}
+	}
+	return sessions, nil
+}
+
+// Issue starts a new session of a player and returns its token.
+func (s *Sessions) Issue(playerID PlayerID) (token string, expiresAt time.Time, err error) {
+	id := make([]byte, sessionIDLength)
+	if _, err = rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt = now.Add(s.ttl)
+	key := sessionKey(id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = s.store.SaveSession(SavedSession{ID: key, PlayerID: playerID, ExpiresAt: expiresAt}); err != nil {
+		return "", time.Time{}, err
+	}
+	for k, sess := range s.sessions { // forget expired sessions, so they don't pile up; the store drops them itself
+		if !now.Before(sess.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[key] = session{playerID: playerID, expiresAt: expiresAt}
+	return s.sign(id), expiresAt, nil
+}
+
+// Resolve returns the player the token was issued to.
+func (s *Sessions) Resolve(token string) (PlayerID, error) {
+	id, ok := s.verify(token)
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	key := sessionKey(id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess, ok := s.sessions[key]
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	if !time.Now().Before(sess.expiresAt) {
+		delete(s.sessions, key)
+		return 0, ErrInvalidToken
+	}
+	return sess.playerID, nil
+}
+
+// Revoke ends the session of the token.
+func (s *Sessions) Revoke(token string) error {
+	id, ok := s.verify(token)
+	if !ok {
+		return ErrInvalidToken
+	}
+	key := sessionKey(id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok = s.sessions[key]; !ok {
+		return ErrInvalidToken
+	}
+	if err := s.store.DeleteSession(key); err != nil {
+		return err
+	}
+	delete(s.sessions, key)
+	return nil
+}
+
+// sessionKey identifies a session without revealing its ID, which together with the secret makes a token.
+func sessionKey(id []byte) string {
+	sum := sha256.Sum256(id)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Sessions) sign(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id) + "." + base64.RawURLEncoding.EncodeToString(s.mac(id))
+}
+
+// verify checks the signature of the token and returns session ID from it.
+func (s *Sessions) verify(token string) ([]byte, bool) {
+	encodedID, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+	id, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil {
+		return nil, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, false
+	}
+	return id, hmac.Equal(mac, s.mac(id))
+}
+
+func (s *Sessions) mac(id []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(id)
+	return h.Sum(nil)
+}
+
+// authenticate is a middleware that lets through only requests with a valid session token in Authorization header.
+// The player of the session is available through currentPlayer.
+func (s *Server) authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		abortWithError(c, ErrInvalidToken)
+		return
+	}
+	player, err := s.playerOfToken(strings.TrimPrefix(header, bearerPrefix))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Set(playerKey, player)
+	withLogFields(c, logrus.Fields{"player_id": player.ID})
+	c.Next()
+}
+
+// authenticateAdmin is a middleware that lets through only requests with Config.AdminToken in Authorization header.
+// Nobody is an admin if the token is not configured.
+func (s *Server) authenticateAdmin(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	if s.cfg.AdminToken == "" || !strings.HasPrefix(header, bearerPrefix) ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, bearerPrefix)), []byte(s.cfg.AdminToken)) != 1 {
+		abortWithError(c, ErrNotAdmin)
+		return
+	}
+	c.Next()
+}
+
+// optionalPlayer returns the player of a request to an endpoint that doesn't require a session, like a websocket
+// connection. Browsers can't set headers of websocket requests, so the session token can be passed in token query
+// parameter too. Requests without a token are anonymous, ok is false for them.
+func (s *Server) optionalPlayer(c *gin.Context) (player Player, ok bool, err error) {
+	token := c.Query("token")
+	if header := c.GetHeader("Authorization"); token == "" && strings.HasPrefix(header, bearerPrefix) {
+		token = strings.TrimPrefix(header, bearerPrefix)
+	}
+	if token == "" {
+		return Player{}, false, nil
+	}
+	player, err = s.playerOfToken(token)
+	if err != nil {
+		return Player{}, false, err
+	}
+	withLogFields(c, logrus.Fields{"player_id": player.ID})
+	return player, true, nil
+}
+
+func (s *Server) playerOfToken(token string) (Player, error) {
+	playerID, err := s.sessions.Resolve(token)
+	if err != nil {
+		return Player{}, err
+	}
+	player, ok := s.playerRegistry.Get(playerID)
+	if !ok {
+		return Player{}, ErrInvalidToken
+	}
+	return player, nil
+}
+
+// currentPlayer returns the player authenticated by authenticate middleware.
+func currentPlayer(c *gin.Context) Player {
+	return c.MustGet(playerKey).(Player)
+}
+
+// sessionToken returns the token the request was authenticated with.
+func sessionToken(c *gin.Context) string {
+	return strings.TrimPrefix(c.GetHeader("Authorization"), bearerPrefix)
+}
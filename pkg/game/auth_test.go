@@ -0,0 +1,70 @@
+package game_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	sessions, err := game.NewSessions([]byte("secret"), time.Hour)
+	require.NoError(t, err)
+	token, expiresAt, err := sessions.Issue(7)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	playerID, err := sessions.Resolve(token)
+	require.NoError(t, err)
+	require.Equal(t, game.PlayerID(7), playerID)
+
+	other, _, err := sessions.Issue(7)
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+
+	require.NoError(t, sessions.Revoke(token))
+	_, err = sessions.Resolve(token)
+	require.ErrorIs(t, err, game.ErrInvalidToken)
+	require.ErrorIs(t, sessions.Revoke(token), game.ErrInvalidToken)
+
+	// other sessions of the player stay valid
+	playerID, err = sessions.Resolve(other)
+	require.NoError(t, err)
+	require.Equal(t, game.PlayerID(7), playerID)
+}
+
+func TestSessionsExpire(t *testing.T) {
+	sessions, err := game.NewSessions(nil, 10*time.Millisecond)
+	require.NoError(t, err)
+	token, _, err := sessions.Issue(1)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = sessions.Resolve(token)
+	require.ErrorIs(t, err, game.ErrInvalidToken)
+}
+
+func TestSessionTTLConfig(t *testing.T) {
+	cfg := game.DefaultConfig()
+	for _, ttl := range []time.Duration{0, -time.Hour} {
+		cfg.SessionTTL = ttl
+		_, err := game.NewServer(cfg)
+		require.Error(t, err, ttl)
+	}
+}
+
+func TestSessionsRejectForgedTokens(t *testing.T) {
+	sessions, err := game.NewSessions([]byte("secret"), time.Hour)
+	require.NoError(t, err)
+	token, _, err := sessions.Issue(1)
+	require.NoError(t, err)
+	// a well-formed token signed with another secret
+	forger, err := game.NewSessions([]byte("guess"), time.Hour)
+	require.NoError(t, err)
+	forged, _, err := forger.Issue(1)
+	require.NoError(t, err)
+
+	for _, token := range []string{"", ".", "abc", "abc.def", token + "A", "A" + token, forged} {
+		_, err = sessions.Resolve(token)
+		require.ErrorIs(t, err, game.ErrInvalidToken, "token %q", token)
+	}
+}
@@ -0,0 +1,48 @@
+package game
+
+import "time"
+
+// Clock tells the current time and measures time for round timers. Tests use it to move time forward without waiting.
+type Clock interface {
+	Now() time.Time
+	// NewTimer is time.NewTimer of the clock: the timer sends the time once d has passed.
+	NewTimer(d time.Duration) ClockTimer
+	// NewTicker is time.NewTicker of the clock: the ticker sends the time every d, dropping ticks nobody read.
+	NewTicker(d time.Duration) ClockTicker
+}
+
+// ClockTimer is a time.Timer of a Clock.
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// ClockTicker is a time.Ticker of a Clock.
+type ClockTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// systemClock is the Clock of the real world.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) ClockTimer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) ClockTicker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+func (t systemTimer) Stop()               { t.Timer.Stop() }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
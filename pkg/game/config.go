@@ -0,0 +1,103 @@
+package game
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config of a Server.
+type Config struct {
+	Addr string `json:"addr" yaml:"addr"` // address to listen on, e.g. ":8080"
+	// CORSOrigins are origins allowed to call the API from a browser. Every origin is allowed if it's empty.
+	CORSOrigins []string `json:"corsOrigins" yaml:"corsOrigins"`
+	// WSCheckOrigin enables origin check for websocket connections. Only CORSOrigins are accepted then, or only the
+	// server's own origin if CORSOrigins is empty.
+	WSCheckOrigin bool `json:"wsCheckOrigin" yaml:"wsCheckOrigin"`
+	// WSPingPeriod is how often websocket clients are pinged. A client that doesn't answer within two periods is
+	// disconnected and its player goes offline.
+	WSPingPeriod    time.Duration `json:"wsPingPeriod" yaml:"wsPingPeriod"`
+	ShutdownTimeout time.Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"` // for graceful shutdown
+	GinMode         string        `json:"ginMode" yaml:"ginMode"`                 // gin.DebugMode, gin.ReleaseMode or gin.TestMode
+	Storage         StorageConfig `json:"storage" yaml:"storage"`
+	AuthSecret      string        `json:"authSecret" yaml:"authSecret"` // signs session tokens, random if empty
+	SessionTTL      time.Duration `json:"sessionTTL" yaml:"sessionTTL"` // how long a session token is valid
+	// AdminToken authenticates operators of the server to admin endpoints. They are disabled if it's empty.
+	AdminToken string `json:"adminToken" yaml:"adminToken"`
+	// MetricsAddr is an address to serve Prometheus metrics on, e.g. ":9090". It's separate from Addr, so metrics
+	// aren't exposed together with the API. Metrics are disabled if it's empty.
+	MetricsAddr string `json:"metricsAddr" yaml:"metricsAddr"`
+	LogLevel    string `json:"logLevel" yaml:"logLevel"`   // debug, info, warn or error
+	LogFormat   string `json:"logFormat" yaml:"logFormat"` // LogFormatLogfmt or LogFormatJSON
+	// LogOutput is where logs are written, os.Stderr if it's nil.
+	LogOutput io.Writer `json:"-" yaml:"-"`
+	// GameTTL is how long a game can stay unchanged before GameExpiry happens to it. Games never expire if it's 0.
+	GameTTL       time.Duration  `json:"gameTTL" yaml:"gameTTL"`
+	GameExpiry    GameExpiry     `json:"gameExpiry" yaml:"gameExpiry"`
+	JanitorPeriod time.Duration  `json:"janitorPeriod" yaml:"janitorPeriod"` // how often idle games are looked for
+	Webhooks      WebhooksConfig `json:"webhooks" yaml:"webhooks"`
+	// Clock is the source of time for games and their round timers, the system clock if it's nil.
+	Clock Clock `json:"-" yaml:"-"`
+}
+
+// DefaultConfig returns configuration suitable for local development.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		WSPingPeriod:    30 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		GinMode:         gin.DebugMode,
+		Storage:         StorageConfig{Backend: StorageMemory},
+		SessionTTL:      24 * time.Hour,
+		LogLevel:        "info",
+		LogFormat:       LogFormatLogfmt,
+		GameTTL:         30 * 24 * time.Hour,
+		GameExpiry:      GameExpiryArchive,
+		JanitorPeriod:   time.Minute,
+		Webhooks: WebhooksConfig{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Timeout:     10 * time.Second,
+		},
+	}
+}
+
+func (cfg Config) corsMiddleware() gin.HandlerFunc {
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowMethods = append(corsConfig.AllowMethods, http.MethodOptions)
+	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, "Authorization")
+	if len(cfg.CORSOrigins) == 0 {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = cfg.CORSOrigins
+	}
+	return cors.New(corsConfig)
+}
+
+// checkWSOrigin returns a function for websocket.Upgrader.CheckOrigin.
+func (cfg Config) checkWSOrigin() func(r *http.Request) bool {
+	if !cfg.WSCheckOrigin {
+		return func(r *http.Request) bool { return true }
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" { // not a browser
+			return true
+		}
+		if len(cfg.CORSOrigins) == 0 {
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		}
+		for _, allowed := range cfg.CORSOrigins {
+			if strings.EqualFold(origin, allowed) {
+				return true
+			}
+		}
+		return false
+	}
+}
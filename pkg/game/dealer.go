@@ -2,122 +2,575 @@ package game
 
 import (
 	"errors"
+	"github.com/sirupsen/logrus"
 	"sort"
 	"sync"
+	"time"
 )
 
 var ErrGameNotFound = errors.New("game not found")
 var ErrPlayerNotInGame = errors.New("player not in game")
+var ErrVotingClosed = errors.New("voting is closed for the current round")
+var ErrGameArchived = errors.New("game is archived and can't be changed")
+var ErrJoinCodeNotFound = errors.New("no game with this join code")
+var ErrDealerClosed = errors.New("dealer is closed")
 
 type GameID uint64 // TODO same as the above
 type Vote string   // TODO well that should probably be an interface? Or some enum
 
+// RoundState is a phase of the current round of a game.
+type RoundState string
+
+const (
+	RoundVoting   RoundState = "voting"   // players vote, votes are hidden
+	RoundClosed   RoundState = "closed"   // no more voting, votes stay hidden until revealed, see TimerActionClose
+	RoundRevealed RoundState = "revealed" // votes are visible, no more voting until a new round starts
+)
+
 // Poker tracks game info. The structure is not ideal and should be reconsidered.
 type Poker struct {
 	ID      GameID              `json:"id"`
+	Code    string              `json:"code"` // join code, unique among games of the Dealer
 	Name    string              `json:"name"`
 	Players map[PlayerID]Player `json:"players"`
+	Roles   map[PlayerID]Role   `json:"roles"` // role of every player in Players
 	Votes   map[PlayerID]Vote   `json:"votes"`
+	State   RoundState          `json:"state"`
+	Deck    Deck                `json:"deck"`
+
+	Stories        []Story `json:"stories"` // backlog in the order of estimation
+	NextStoryID    StoryID `json:"nextStoryId"`
+	CurrentStoryID StoryID `json:"currentStoryId"` // 0 if no story is being estimated
+	History        []Round `json:"history"`        // completed rounds of all stories
+
+	CreatedBy    PlayerID     `json:"createdBy"` // 0 for games created before creators were recorded
+	CreatedAt    time.Time    `json:"createdAt"`
+	LastActivity time.Time    `json:"lastActivity"` // when the game was changed last time
+	Archived     bool         `json:"archived"`     // an archived game is kept read-only, see ExpireIdleGames
+	Settings     GameSettings `json:"settings"`
+
+	Webhooks      []Webhook `json:"webhooks,omitempty"` // get events of the game, see AddWebhook
+	NextWebhookID WebhookID `json:"nextWebhookId"`
+
+	// Timer and Presence exist only while the server runs, so they are not saved.
+	Timer    *RoundTimer            `json:"-"` // countdown of the current round
+	Presence map[PlayerID]*presence `json:"-"` // players connected to the game's websocket
 }
 
 // Dealer controls all games.
 type Dealer struct {
 	nextGameID GameID
-	games      map[GameID]*Poker
-	lock       sync.RWMutex // protects nextGameID and games. This can be changed in the future to work with channels
+	games      map[GameID]*table
+	codes      map[string]GameID // join codes of games
+	lock       sync.RWMutex      // protects nextGameID, games and codes, but not the games themselves
+	hub        *Hub              // publishes changes of games to subscribers
+	store      GameStore         // every change of a game is saved here
+	metrics    *Metrics          // nil if not instrumented
+	log        logrus.FieldLogger
+	clock      Clock
+
+	countdowns        map[GameID]*countdown // running timers of rounds
+	closed            bool                  // set by Close, no timers are started after it
+	countdownLock     sync.Mutex            // protects countdowns and closed
+	countdownsRunning sync.WaitGroup
+
+	webhooks *webhookSender // nil until webhooks are started, see startWebhooks
+}
+
+// table guards a single game, so players of different games don't wait for each other. Events of a game are
+// published while its lock is held, so subscribers get them in the order of changes.
+type table struct {
+	poker   *Poker
+	saved   *Poker       // a copy of poker as it was saved last, see commit
+	deleted bool         // set when the game is deleted while someone waits for the lock
+	lock    sync.RWMutex // protects poker, saved and deleted
+}
+
+func newTable(poker *Poker) *table {
+	return &table{poker: poker, saved: poker.clone()}
+}
+
+// commit saves the game to the store. If that fails, the game is rolled back to how it was saved last, so it doesn't
+// drift from the store and callers return the error before publishing the change. Timer and Presence are not saved,
+// so they are kept as they are. The table must be locked.
+func (t *table) commit(store GameStore) error {
+	if err := store.SaveGame(t.poker); err != nil {
+		timer, presence := t.poker.Timer, t.poker.Presence
+		*t.poker = *t.saved.clone()
+		t.poker.Timer, t.poker.Presence = timer, presence
+		return err
+	}
+	t.saved = t.poker.clone()
+	return nil
+}
+
+// clone returns a copy of the saved fields of the game that shares nothing the Dealer changes in place.
+func (p *Poker) clone() *Poker {
+	c := *p
+	c.Players = make(map[PlayerID]Player, len(p.Players))
+	for id, player := range p.Players {
+		c.Players[id] = player
+	}
+	c.Roles = make(map[PlayerID]Role, len(p.Roles))
+	for id, role := range p.Roles {
+		c.Roles[id] = role
+	}
+	c.Votes = make(map[PlayerID]Vote, len(p.Votes))
+	for id, vote := range p.Votes {
+		c.Votes[id] = vote
+	}
+	c.Deck.Cards = append([]Vote(nil), p.Deck.Cards...)
+	c.Stories = append(make([]Story, 0, len(p.Stories)), p.Stories...)
+	c.History = append(make([]Round, 0, len(p.History)), p.History...) // rounds are never changed once completed
+	c.Webhooks = append([]Webhook(nil), p.Webhooks...)
+	c.Timer, c.Presence = nil, nil
+	return &c
 }
 
-// NewDealer creates a new instance of a Dealer with nextGameID set to 1.
+// NewDealer creates a new instance of a Dealer with nextGameID set to 1 that keeps games only in memory.
 func NewDealer() *Dealer {
 	return &Dealer{
 		nextGameID: 1,
-		games:      make(map[GameID]*Poker), // TODO why do we store here a pointer, but in registry - a struct
+		games:      make(map[GameID]*table),
+		codes:      make(map[string]GameID),
 		lock:       sync.RWMutex{},
+		hub:        NewHub(),
+		store:      MemoryStore{},
+		log:        logrus.StandardLogger(),
+		clock:      systemClock{},
+		countdowns: make(map[GameID]*countdown),
+	}
+}
+
+// NewDealerWithStore creates a Dealer with games loaded from store. All further changes are saved to store.
+func NewDealerWithStore(store GameStore) (*Dealer, error) {
+	games, nextGameID, err := store.LoadGames()
+	if err != nil {
+		return nil, err
+	}
+	dealer := NewDealer()
+	dealer.store = store
+	dealer.nextGameID = nextGameID
+	for _, poker := range games {
+		ensureRoles(poker)
+		if poker.Code == "" { // saved before games had join codes
+			if poker.Code, err = dealer.newJoinCode(); err != nil {
+				return nil, err
+			}
+			if err = store.SaveGame(poker); err != nil {
+				return nil, err
+			}
+		}
+		dealer.games[poker.ID] = newTable(poker)
+		dealer.codes[poker.Code] = poker.ID
+	}
+	return dealer, nil
+}
+
+// lockGame finds a game and locks it for changes. Archived games can't be changed. The lock of the Dealer is held
+// only while looking the game up; it may be taken again while the game is locked, but never the other way round.
+func (d *Dealer) lockGame(id GameID) (game *Poker, unlock func(), err error) {
+	game, unlock, err = d.lockAnyGame(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if game.Archived {
+		unlock()
+		return nil, nil, ErrGameArchived
+	}
+	return game, unlock, nil
+}
+
+// lockAnyGame is like lockGame, but archived games are locked too.
+func (d *Dealer) lockAnyGame(id GameID) (game *Poker, unlock func(), err error) {
+	d.lock.RLock()
+	t, ok := d.games[id]
+	d.lock.RUnlock()
+	if !ok {
+		return nil, nil, &GameNotFoundError{GameID: id}
+	}
+	t.lock.Lock()
+	if t.deleted {
+		t.lock.Unlock()
+		return nil, nil, &GameNotFoundError{GameID: id}
+	}
+	return t.poker, t.lock.Unlock, nil
+}
+
+// saveGame marks the game as active and saves it to the store. Every change of a game is saved with it. If saving
+// fails, the game is rolled back, see table.commit. The game must be locked; the lock of the Dealer is taken after it,
+// like in removeGame.
+func (d *Dealer) saveGame(game *Poker) error {
+	d.lock.RLock()
+	t := d.games[game.ID]
+	d.lock.RUnlock()
+	game.LastActivity = d.clock.Now()
+	return t.commit(d.store)
+}
+
+// readGame is like lockGame, but the game is locked only for reading.
+func (d *Dealer) readGame(id GameID) (game *Poker, unlock func(), err error) {
+	d.lock.RLock()
+	t, ok := d.games[id]
+	d.lock.RUnlock()
+	if !ok {
+		return nil, nil, &GameNotFoundError{GameID: id}
 	}
+	t.lock.RLock()
+	if t.deleted {
+		t.lock.RUnlock()
+		return nil, nil, &GameNotFoundError{GameID: id}
+	}
+	return t.poker, t.lock.RUnlock, nil
 }
 
-// CreateGame starts a new game with creator as participant.
-func (d *Dealer) CreateGame(name string, creator Player) (GameResponse, error) { // TODO not sure if this should return a pointer
+// CreateGame starts a new game with creator as its facilitator. Players of the game can vote only with cards from deck.
+func (d *Dealer) CreateGame(name string, creator Player, deck Deck) (GameResponse, error) { // TODO not sure if this should return a pointer
 	d.lock.Lock()
 	defer d.lock.Unlock()
+	code, err := d.newJoinCode()
+	if err != nil {
+		return GameResponse{}, err
+	}
+	now := d.clock.Now()
 	poker := Poker{
 		ID:      d.nextGameID,
+		Code:    code,
 		Players: map[PlayerID]Player{creator.ID: creator},
+		Roles:   map[PlayerID]Role{creator.ID: RoleFacilitator},
 		Votes:   map[PlayerID]Vote{},
 		Name:    name,
+		State:   RoundVoting,
+		Deck:    deck,
+		Stories: []Story{},
+		History: []Round{},
+
+		CreatedBy:    creator.ID,
+		CreatedAt:    now,
+		LastActivity: now,
+	}
+	// The ID is saved before the game, so it is never reused even if saving the game fails.
+	if err := d.store.SaveNextGameID(d.nextGameID + 1); err != nil {
+		return GameResponse{}, err
 	}
 	d.nextGameID++
-	d.games[poker.ID] = &poker
-	return gameToResponse(&poker), nil
-}
-
-// ListGameNames returns names and ids of all present games sorted by name.
-func (d *Dealer) ListGameNames() []GameListEntry {
-	d.lock.RLock()
-	defer d.lock.RUnlock()
-	gamesList := make([]GameListEntry, 0, len(d.games))
-	for _, v := range d.games {
-		gamesList = append(gamesList, GameListEntry{
-			ID:   v.ID,
-			Name: v.Name,
-		})
+	if err := d.store.SaveGame(&poker); err != nil { // not saveGame, the game isn't in the Dealer yet
+		return GameResponse{}, err
 	}
-	sort.Slice(gamesList, func(i, j int) bool { return gamesList[i].Name < gamesList[j].Name })
-	return gamesList
+	d.games[poker.ID] = newTable(&poker)
+	d.codes[code] = poker.ID
+	d.metrics.gameCreated()
+	d.log.WithFields(logrus.Fields{"game_id": poker.ID, "player_id": creator.ID}).Info("Game created")
+	resp := gameToResponse(&poker)
+	d.hub.Publish(NewEvent(EventGameCreated, poker.ID, resp))
+	return resp, nil
 }
 
 // GetGame returns information about the game by its ID. Players inside a game are sorted by name.
 func (d *Dealer) GetGame(id GameID) (GameResponse, bool) {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	poker, ok := d.games[id]
-	if !ok {
-		return GameResponse{}, ok
+	poker, unlock, err := d.readGame(id)
+	if err != nil {
+		return GameResponse{}, false
 	}
-	return gameToResponse(poker), ok
+	defer unlock()
+	return gameToResponse(poker), true
 }
 
+// JoinGame adds a player to the game as a voter or an observer. Joining again changes the role of the player, the
+// vote of a player who becomes an observer is dropped.
 // TODO we obviously don't handle the case where player is deleted while they are in a game
-// Also same player can potentially be added twice (well, overwritten technically).
-func (d *Dealer) JoinGame(gameID GameID, player Player) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	game, ok := d.games[gameID]
-	if !ok {
-		return ErrGameNotFound
+func (d *Dealer) JoinGame(gameID GameID, player Player, role Role) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	role, err = joinRole(game, player.ID, role)
+	if err != nil {
+		return err
 	}
 	game.Players[player.ID] = player
+	game.Roles[player.ID] = role
+	if role == RoleObserver && game.State != RoundRevealed {
+		delete(game.Votes, player.ID)
+	}
+	electFacilitator(game) // the first player to join a game everyone left runs it
+	if err := d.saveGame(game); err != nil {
+		return err
+	}
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "player_id": player.ID, "role": role}).Info("Player joined")
+	d.hub.Publish(NewEvent(EventPlayerJoined, gameID, playerToResponse(game, player)))
+	d.autoReveal(game) // a voter who becomes an observer may be the last one the round waited for
 	return nil
 }
 
-func (d *Dealer) Vote(gameId GameID, voteReq VoteRequest) error {
-	d.lock.Lock() // TODO full lock here just to vote... That's not scalable.
-	defer d.lock.Unlock()
-	game, ok := d.games[gameId]
-	if !ok {
-		return ErrGameNotFound
+// LeaveGame removes the player from the game together with their vote. If the facilitator leaves, another player
+// takes over the role.
+func (d *Dealer) LeaveGame(gameID GameID, playerID PlayerID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return d.removePlayer(game, playerID, false)
+}
+
+// RemovePlayer removes another player from the game together with their vote. Only the facilitator can remove
+// players.
+func (d *Dealer) RemovePlayer(gameID GameID, facilitatorID PlayerID, playerID PlayerID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, facilitatorID); err != nil {
+		return err
+	}
+	return d.removePlayer(game, playerID, true)
+}
+
+func (d *Dealer) removePlayer(game *Poker, playerID PlayerID, removed bool) error {
+	if _, ok := game.Players[playerID]; !ok {
+		return &NotInGameError{GameID: game.ID, PlayerID: playerID}
+	}
+	delete(game.Players, playerID)
+	delete(game.Roles, playerID)
+	delete(game.Votes, playerID)
+	facilitator := electFacilitator(game)
+	if err := d.saveGame(game); err != nil {
+		return err
+	}
+	d.log.WithFields(logrus.Fields{"game_id": game.ID, "player_id": playerID, "removed": removed}).Info("Player left")
+	d.hub.Publish(NewEvent(EventPlayerLeft, game.ID, PlayerLeft{PlayerID: playerID, Removed: removed}))
+	if facilitator != 0 {
+		d.hub.Publish(NewEvent(EventRolesChanged, game.ID, RolesChanged{Players: []PlayerResponse{
+			playerToResponse(game, game.Players[facilitator]),
+		}}))
+	}
+	d.autoReveal(game)
+	return nil
+}
+
+// DeleteGame deletes the game and ends all subscriptions to it. Only the facilitator can delete a game, archived
+// games can be deleted too.
+func (d *Dealer) DeleteGame(gameID GameID, playerID PlayerID) error {
+	game, unlock, err := d.lockAnyGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return err
+	}
+	if err := d.removeGame(gameID); err != nil {
+		return err
+	}
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "player_id": playerID}).Info("Game deleted")
+	d.hub.Publish(NewEvent(EventGameDeleted, gameID, nil))
+	d.hub.CloseGame(gameID)
+	return nil
+}
+
+// removeGame deletes the game from the store and from the Dealer. The game must be locked.
+func (d *Dealer) removeGame(gameID GameID) error {
+	if err := d.store.DeleteGame(gameID); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	t := d.games[gameID]
+	delete(d.games, gameID)
+	delete(d.codes, t.poker.Code)
+	d.lock.Unlock()
+	t.deleted = true
+	d.cancelTimer(t.poker)
+	if !t.poker.Archived { // archived games are not counted
+		d.metrics.gameDeleted()
+	}
+	return nil
+}
+
+// Vote records a vote of a player in the current round.
+func (d *Dealer) Vote(gameId GameID, playerID PlayerID, vote Vote) error {
+	game, unlock, err := d.lockGame(gameId)
+	if err != nil {
+		return err
 	}
-	player, ok := game.Players[voteReq.PlayerID]
+	defer unlock()
+	player, ok := game.Players[playerID]
 	if !ok {
-		return ErrPlayerNotInGame
+		return &NotInGameError{GameID: gameId, PlayerID: playerID}
+	}
+	if game.Roles[player.ID] == RoleObserver {
+		return &ForbiddenError{PlayerID: playerID, Reason: ErrObserverCannotVote}
 	}
-	game.Votes[player.ID] = voteReq.Vote
+	if game.State != RoundVoting {
+		return ErrVotingClosed
+	}
+	if !game.Deck.Contains(vote) {
+		return &InvalidVoteError{Vote: vote}
+	}
+	game.Votes[player.ID] = vote
+	if err := d.saveGame(game); err != nil {
+		return err
+	}
+	d.metrics.voteCast()
+	d.hub.Publish(NewEvent(EventVoteCast, gameId, VoteCast{PlayerID: player.ID}))
+	d.autoReveal(game)
 	return nil
 }
 
+// Reveal makes votes of the current round visible and adds the round to the game's history. Revealing an already
+// revealed round does nothing. Only the facilitator can reveal.
+func (d *Dealer) Reveal(gameID GameID, playerID PlayerID) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	if game.State == RoundRevealed {
+		return gameToResponse(game), nil
+	}
+	return d.revealRound(game)
+}
+
+// revealRound makes votes visible, stops the timer of the round and lets subscribers know. The game must be locked.
+func (d *Dealer) revealRound(game *Poker) (GameResponse, error) {
+	game.State = RoundRevealed
+	completeRound(game, d.clock.Now())
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.cancelTimer(game)
+	resp := gameToResponse(game)
+	event := resp
+	event.Code = "" // subscribers may be anonymous
+	d.hub.Publish(NewEvent(EventRoundRevealed, game.ID, event))
+	return resp, nil
+}
+
+// NewRound drops all votes and starts voting again. Only the facilitator can start a round.
+func (d *Dealer) NewRound(gameID GameID, playerID PlayerID) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	d.startRound(game)
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.cancelTimer(game)
+	d.hub.Publish(NewEvent(EventRoundStarted, gameID, nil))
+	return gameToResponse(game), nil
+}
+
+// Subscribe returns current state of the game together with a subscription to its further changes. Both are taken
+// under the lock of the game, so no change is missed between the snapshot and the first event. An archived game
+// doesn't change, so the subscription to it is already closed.
+func (d *Dealer) Subscribe(gameID GameID) (GameResponse, *Subscription, error) {
+	poker, unlock, err := d.readGame(gameID)
+	if err != nil {
+		return GameResponse{}, nil, err
+	}
+	defer unlock()
+	if poker.Archived {
+		sub := &Subscription{gameID: gameID, events: make(chan Event)}
+		close(sub.events)
+		return gameToResponse(poker), sub, nil
+	}
+	return gameToResponse(poker), d.hub.Subscribe(gameID), nil
+}
+
+// Unsubscribe stops the subscription received from Subscribe.
+func (d *Dealer) Unsubscribe(sub *Subscription) {
+	d.hub.Unsubscribe(sub)
+}
+
+// instrument starts recording metrics of games and their subscriptions. It must be called before the Dealer is used.
+func (d *Dealer) instrument(metrics *Metrics) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.metrics = metrics
+	d.hub.metrics = metrics
+	active := 0
+	for _, t := range d.games { // no need to lock tables, the Dealer is not used yet
+		if !t.poker.Archived {
+			active++
+		}
+	}
+	metrics.setGames(active)
+}
+
+// setLogger makes the Dealer and its Hub log to log. It must be called before the Dealer is used.
+func (d *Dealer) setLogger(log logrus.FieldLogger) {
+	d.log = log
+	d.hub.log = log
+}
+
+// Close stops all timers, ends all subscriptions to games and waits for webhook deliveries in progress.
+func (d *Dealer) Close() {
+	d.stopTimers()
+	d.hub.Close()
+	d.webhooks.close()
+}
+
 func gameToResponse(poker *Poker) GameResponse {
 	resp := GameResponse{
 		ID:      poker.ID,
+		Code:    poker.Code,
 		Name:    poker.Name,
+		State:   poker.State,
+		Deck:    poker.Deck,
 		Players: make([]PlayerResponse, 0, len(poker.Players)),
+
+		CurrentStoryID: poker.CurrentStoryID,
+
+		CreatedBy:    poker.CreatedBy,
+		CreatedAt:    poker.CreatedAt,
+		LastActivity: poker.LastActivity,
+		Archived:     poker.Archived,
+		Timer:        poker.Timer,
+		Settings:     poker.Settings,
 	}
+	resp.Stories, resp.Rounds = storiesToResponse(poker)
 	for _, player := range poker.Players {
-		resp.Players = append(resp.Players, PlayerResponse{
-			ID:   player.ID,
-			Name: player.Name,
-			Vote: poker.Votes[player.ID],
-		})
+		resp.Players = append(resp.Players, playerToResponse(poker, player))
+	}
+	if poker.State == RoundRevealed {
+		stats := ComputeStats(poker.Deck, poker.Votes)
+		resp.Stats = &stats
+	}
+	sort.Slice(resp.Players, func(i, j int) bool {
+		if resp.Players[i].Name != resp.Players[j].Name {
+			return resp.Players[i].Name < resp.Players[j].Name
+		}
+		return resp.Players[i].ID < resp.Players[j].ID // names are not unique
+	})
+	return resp
+}
+
+// playerToResponse shows the vote of the player only if the round is revealed.
+func playerToResponse(poker *Poker, player Player) PlayerResponse {
+	vote, voted := poker.Votes[player.ID]
+	resp := PlayerResponse{
+		ID:    player.ID,
+		Name:  player.Name,
+		Role:  poker.Roles[player.ID],
+		Voted: voted,
+	}
+	if poker.State == RoundRevealed {
+		resp.Vote = vote
+	}
+	if p, ok := poker.Presence[player.ID]; ok {
+		resp.Online = p.connections > 0
+		lastSeen := p.lastSeen
+		resp.LastSeen = &lastSeen
 	}
-	sort.Slice(resp.Players, func(i, j int) bool { return resp.Players[i].Name < resp.Players[j].Name })
 	return resp
 }
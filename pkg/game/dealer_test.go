@@ -0,0 +1,239 @@
+package game_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	benchGames          = 64
+	benchPlayersPerGame = 8
+)
+
+func TestConcurrentVotesWhileGamesAreDeleted(t *testing.T) {
+	dealer := game.NewDealer()
+	defer dealer.Close()
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	var wg sync.WaitGroup // require can't stop the test from other goroutines, so they use assert
+	for i := 0; i < 16; i++ {
+		poker, err := dealer.CreateGame(fmt.Sprintf("game%d", i), creator, deck)
+		require.NoError(t, err)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				err := dealer.Vote(poker.ID, creator.ID, "5")
+				if err != nil {
+					assert.ErrorIs(t, err, game.ErrGameNotFound)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, sub, err := dealer.Subscribe(poker.ID)
+			if err == nil {
+				defer dealer.Unsubscribe(sub)
+			}
+			assert.NoError(t, dealer.DeleteGame(poker.ID, creator.ID))
+		}()
+	}
+	wg.Wait()
+	games, err := dealer.ListGames(game.ListGamesRequest{Status: game.GameStatusAll})
+	require.NoError(t, err)
+	require.Empty(t, games.Games)
+}
+
+func TestFailedSaveRollsBackGame(t *testing.T) {
+	store := &failingStore{}
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	defer dealer.Close()
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	voter := game.Player{ID: 2, Name: "alice"}
+	created, err := dealer.CreateGame("sprint", creator, deck)
+	require.NoError(t, err)
+	require.NoError(t, dealer.JoinGame(created.ID, voter, game.RoleVoter))
+	require.NoError(t, dealer.Vote(created.ID, voter.ID, "5"))
+	_, err = dealer.StartTimer(created.ID, creator.ID, time.Minute, game.TimerActionReveal)
+	require.NoError(t, err)
+	before, _ := dealer.GetGame(created.ID)
+	_, sub, err := dealer.Subscribe(created.ID)
+	require.NoError(t, err)
+	defer dealer.Unsubscribe(sub)
+
+	store.fail(true)
+	require.ErrorIs(t, dealer.Vote(created.ID, creator.ID, "3"), errStoreFailed)
+	require.ErrorIs(t, dealer.JoinGame(created.ID, game.Player{ID: 3, Name: "carol"}, game.RoleVoter), errStoreFailed)
+	require.ErrorIs(t, dealer.LeaveGame(created.ID, voter.ID), errStoreFailed)
+	_, err = dealer.AddStory(created.ID, creator.ID, game.StoryRequest{Title: "Login page"})
+	require.ErrorIs(t, err, errStoreFailed)
+	_, err = dealer.Reveal(created.ID, creator.ID)
+	require.ErrorIs(t, err, errStoreFailed)
+	_, err = dealer.NewRound(created.ID, creator.ID)
+	require.ErrorIs(t, err, errStoreFailed)
+	_, err = dealer.StartTimer(created.ID, creator.ID, time.Minute, game.TimerActionClose)
+	require.ErrorIs(t, err, errStoreFailed)
+	_, err = dealer.RenameGame(created.ID, creator.ID, "retro")
+	require.ErrorIs(t, err, errStoreFailed)
+
+	after, _ := dealer.GetGame(created.ID)
+	require.Equal(t, before, after) // the timer still runs too
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("%s was published, but not saved", event.Type)
+	default:
+	}
+
+	store.fail(false)
+	require.NoError(t, dealer.Vote(created.ID, creator.ID, "3"))
+	require.Equal(t, game.EventVoteCast, (<-sub.Events()).Type)
+}
+
+func TestFailedAutoRevealKeepsVote(t *testing.T) {
+	store := &failingStore{failRevealed: true}
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	defer dealer.Close()
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	created, err := dealer.CreateGame("sprint", creator, deck)
+	require.NoError(t, err)
+	autoReveal := true
+	_, err = dealer.UpdateSettings(created.ID, creator.ID, game.GameSettingsRequest{AutoReveal: &autoReveal})
+	require.NoError(t, err)
+
+	// the vote is saved, so it doesn't fail even though the round can't be revealed
+	require.NoError(t, dealer.Vote(created.ID, creator.ID, "5"))
+	poker, _ := dealer.GetGame(created.ID)
+	require.Equal(t, game.RoundVoting, poker.State)
+	require.True(t, poker.Players[0].Voted)
+	require.Empty(t, poker.Players[0].Vote)
+}
+
+// BenchmarkConcurrentVotes measures throughput of votes in many games at once, every goroutine votes in its own game.
+func BenchmarkConcurrentVotes(b *testing.B) {
+	dealer, games := benchDealer(b)
+	var next uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		poker := games[atomic.AddUint64(&next, 1)%benchGames]
+		for i := 0; pb.Next(); i++ {
+			player := poker.Players[i%benchPlayersPerGame]
+			if err := dealer.Vote(poker.ID, player.ID, "5"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkConcurrentVotesSlowStore is like BenchmarkConcurrentVotes, but saving a game takes time, as it does with
+// a store on a slow disk.
+func BenchmarkConcurrentVotesSlowStore(b *testing.B) {
+	dealer, games := benchDealerWithStore(b, slowStore{delay: 50 * time.Microsecond})
+	var next uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		poker := games[atomic.AddUint64(&next, 1)%benchGames]
+		for i := 0; pb.Next(); i++ {
+			player := poker.Players[i%benchPlayersPerGame]
+			if err := dealer.Vote(poker.ID, player.ID, "5"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkConcurrentVotesAndReads is like BenchmarkConcurrentVotes, but every other operation reads the game.
+func BenchmarkConcurrentVotesAndReads(b *testing.B) {
+	dealer, games := benchDealer(b)
+	var next uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		poker := games[atomic.AddUint64(&next, 1)%benchGames]
+		for i := 0; pb.Next(); i++ {
+			if i%2 == 0 {
+				if _, ok := dealer.GetGame(poker.ID); !ok {
+					b.Fatalf("game %d not found", poker.ID)
+				}
+				continue
+			}
+			player := poker.Players[i%benchPlayersPerGame]
+			if err := dealer.Vote(poker.ID, player.ID, "5"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func benchDealer(b *testing.B) (*game.Dealer, []game.GameResponse) {
+	return benchDealerWithStore(b, game.MemoryStore{})
+}
+
+// benchDealerWithStore creates benchGames games with benchPlayersPerGame players each.
+func benchDealerWithStore(b *testing.B, store game.GameStore) (*game.Dealer, []game.GameResponse) {
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(b, err)
+	b.Cleanup(dealer.Close)
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(b, err)
+	games := make([]game.GameResponse, 0, benchGames)
+	playerID := game.PlayerID(1)
+	for i := 0; i < benchGames; i++ {
+		creator := game.Player{ID: playerID, Name: fmt.Sprintf("player%d", playerID)}
+		playerID++
+		poker, err := dealer.CreateGame(fmt.Sprintf("game%d", i), creator, deck)
+		require.NoError(b, err)
+		for j := 1; j < benchPlayersPerGame; j++ {
+			player := game.Player{ID: playerID, Name: fmt.Sprintf("player%d", playerID)}
+			playerID++
+			require.NoError(b, dealer.JoinGame(poker.ID, player, game.RoleVoter))
+		}
+		poker, _ = dealer.GetGame(poker.ID)
+		games = append(games, poker)
+	}
+	return dealer, games
+}
+
+// slowStore keeps nothing, but takes delay to save a game.
+type slowStore struct {
+	game.MemoryStore
+	delay time.Duration
+}
+
+func (s slowStore) SaveGame(*game.Poker) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+var errStoreFailed = errors.New("disk is full")
+
+// failingStore keeps nothing, and fails to save games while it's told to, or revealed games if failRevealed is set.
+type failingStore struct {
+	game.MemoryStore
+	failing      atomic.Value // bool
+	failRevealed bool
+}
+
+func (s *failingStore) fail(failing bool) {
+	s.failing.Store(failing)
+}
+
+func (s *failingStore) SaveGame(poker *game.Poker) error {
+	if failing, _ := s.failing.Load().(bool); failing || (s.failRevealed && poker.State == game.RoundRevealed) {
+		return errStoreFailed
+	}
+	return nil
+}
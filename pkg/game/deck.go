@@ -0,0 +1,109 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidDeck = errors.New("invalid deck")
+
+// DeckType names a set of cards players can vote with.
+type DeckType string
+
+const (
+	DeckFibonacci         DeckType = "fibonacci"
+	DeckModifiedFibonacci DeckType = "modified_fibonacci"
+	DeckTShirt            DeckType = "tshirt"
+	DeckPowersOfTwo       DeckType = "powers_of_two"
+	DeckCustom            DeckType = "custom" // cards are provided by the game creator
+)
+
+// Special cards that are part of every built-in deck.
+const (
+	CardUnsure Vote = "?"
+	CardCoffee Vote = "☕" // player needs a break
+)
+
+const (
+	maxCustomCards   = 32
+	maxCardNameRunes = 16
+)
+
+var builtinDecks = map[DeckType][]Vote{
+	DeckFibonacci:         {"0", "1", "2", "3", "5", "8", "13", "21", "34", "55", "89", CardUnsure, CardCoffee},
+	DeckModifiedFibonacci: {"0", "½", "1", "2", "3", "5", "8", "13", "20", "40", "100", CardUnsure, CardCoffee},
+	DeckTShirt:            {"XS", "S", "M", "L", "XL", "XXL", CardUnsure, CardCoffee},
+	DeckPowersOfTwo:       {"0", "1", "2", "4", "8", "16", "32", "64", CardUnsure, CardCoffee},
+}
+
+// InvalidVoteError is returned when a vote is not one of the cards in the game's deck.
+type InvalidVoteError struct {
+	Vote Vote
+}
+
+func (e *InvalidVoteError) Error() string {
+	return fmt.Sprintf("vote %q is not a card in the game's deck", string(e.Vote))
+}
+
+// Deck is an ordered set of cards that can be used to vote in a game.
+type Deck struct {
+	Type  DeckType `json:"type"`
+	Cards []Vote   `json:"cards"`
+}
+
+// NewDeck returns a built-in deck by its type or a custom deck made of cards. Empty type means Fibonacci deck.
+// Cards must be provided only for a custom deck.
+func NewDeck(deckType DeckType, cards []Vote) (Deck, error) {
+	if deckType == "" {
+		deckType = DeckFibonacci
+	}
+	if deckType == DeckCustom {
+		return newCustomDeck(cards)
+	}
+	builtin, ok := builtinDecks[deckType]
+	if !ok {
+		return Deck{}, fmt.Errorf("%w: unknown deck type %q", ErrInvalidDeck, deckType)
+	}
+	if len(cards) != 0 {
+		return Deck{}, fmt.Errorf("%w: cards can be set only for %q deck", ErrInvalidDeck, DeckCustom)
+	}
+	return Deck{
+		Type:  deckType,
+		Cards: append([]Vote(nil), builtin...), // copy, so games can't modify built-in decks
+	}, nil
+}
+
+func newCustomDeck(cards []Vote) (Deck, error) {
+	if len(cards) == 0 || len(cards) > maxCustomCards {
+		return Deck{}, fmt.Errorf("%w: custom deck must have from 1 to %d cards", ErrInvalidDeck, maxCustomCards)
+	}
+	seen := make(map[Vote]struct{}, len(cards))
+	for _, card := range cards {
+		if strings.TrimSpace(string(card)) != string(card) || card == "" {
+			return Deck{}, fmt.Errorf("%w: card %q is empty or has surrounding spaces", ErrInvalidDeck, string(card))
+		}
+		if utf8.RuneCountInString(string(card)) > maxCardNameRunes {
+			return Deck{}, fmt.Errorf("%w: card %q is longer than %d characters", ErrInvalidDeck, string(card), maxCardNameRunes)
+		}
+		if _, ok := seen[card]; ok {
+			return Deck{}, fmt.Errorf("%w: card %q is repeated", ErrInvalidDeck, string(card))
+		}
+		seen[card] = struct{}{}
+	}
+	return Deck{
+		Type:  DeckCustom,
+		Cards: append([]Vote(nil), cards...),
+	}, nil
+}
+
+// Contains tells if vote is one of the cards of the deck.
+func (d Deck) Contains(vote Vote) bool {
+	for _, card := range d.Cards {
+		if card == vote {
+			return true
+		}
+	}
+	return false
+}
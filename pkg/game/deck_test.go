@@ -0,0 +1,67 @@
+package game_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"testing"
+)
+
+func TestNewBuiltinDecks(t *testing.T) {
+	for _, deckType := range []game.DeckType{
+		game.DeckFibonacci,
+		game.DeckModifiedFibonacci,
+		game.DeckTShirt,
+		game.DeckPowersOfTwo,
+	} {
+		deck, err := game.NewDeck(deckType, nil)
+		require.NoError(t, err)
+		require.Equal(t, deckType, deck.Type)
+		require.True(t, deck.Contains(game.CardUnsure))
+		require.True(t, deck.Contains(game.CardCoffee))
+	}
+}
+
+func TestNewDeckDefaultsToFibonacci(t *testing.T) {
+	deck, err := game.NewDeck("", nil)
+	require.NoError(t, err)
+	require.Equal(t, game.DeckFibonacci, deck.Type)
+}
+
+func TestBuiltinDeckIsNotShared(t *testing.T) {
+	deck, err := game.NewDeck(game.DeckTShirt, nil)
+	require.NoError(t, err)
+	deck.Cards[0] = "XXXS"
+	deck, err = game.NewDeck(game.DeckTShirt, nil)
+	require.NoError(t, err)
+	require.Equal(t, game.Vote("XS"), deck.Cards[0])
+}
+
+func TestNewDeckErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		deckType game.DeckType
+		cards    []game.Vote
+	}{
+		{name: "unknown type", deckType: "tarot"},
+		{name: "cards for built-in deck", deckType: game.DeckFibonacci, cards: []game.Vote{"1"}},
+		{name: "custom without cards", deckType: game.DeckCustom},
+		{name: "empty card", deckType: game.DeckCustom, cards: []game.Vote{"1", ""}},
+		{name: "card with spaces", deckType: game.DeckCustom, cards: []game.Vote{"8 "}},
+		{name: "repeated card", deckType: game.DeckCustom, cards: []game.Vote{"1", "1"}},
+		{name: "too long card", deckType: game.DeckCustom, cards: []game.Vote{"abcdefghijklmnopq"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := game.NewDeck(test.deckType, test.cards)
+			require.ErrorIs(t, err, game.ErrInvalidDeck)
+		})
+	}
+}
+
+func TestDeckContains(t *testing.T) {
+	deck, err := game.NewDeck(game.DeckCustom, []game.Vote{"1", "coffee"})
+	require.NoError(t, err)
+	require.True(t, deck.Contains("coffee"))
+	require.False(t, deck.Contains("2"))
+	require.False(t, deck.Contains("1 "))
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrPlayerNotFound = errors.New("player not found")
+
+// GameNotFoundError is returned when a game doesn't exist. It matches ErrGameNotFound.
+type GameNotFoundError struct {
+	GameID GameID
+}
+
+func (e *GameNotFoundError) Error() string {
+	return fmt.Sprintf("game %d not found", e.GameID)
+}
+
+func (e *GameNotFoundError) Is(target error) bool {
+	return target == ErrGameNotFound
+}
+
+// PlayerNotFoundError is returned when a player isn't registered. It matches ErrPlayerNotFound.
+type PlayerNotFoundError struct {
+	PlayerID PlayerID
+}
+
+func (e *PlayerNotFoundError) Error() string {
+	return fmt.Sprintf("player %d not found", e.PlayerID)
+}
+
+func (e *PlayerNotFoundError) Is(target error) bool {
+	return target == ErrPlayerNotFound
+}
+
+// NotInGameError is returned when a player is not a member of a game. It matches ErrPlayerNotInGame.
+type NotInGameError struct {
+	GameID   GameID
+	PlayerID PlayerID
+}
+
+func (e *NotInGameError) Error() string {
+	return fmt.Sprintf("player %d not in game %d", e.PlayerID, e.GameID)
+}
+
+func (e *NotInGameError) Is(target error) bool {
+	return target == ErrPlayerNotInGame
+}
+
+// StoryNotFoundError is returned when a game has no such story. It matches ErrStoryNotFound.
+type StoryNotFoundError struct {
+	GameID  GameID
+	StoryID StoryID
+}
+
+func (e *StoryNotFoundError) Error() string {
+	return fmt.Sprintf("story %d not found in game %d", e.StoryID, e.GameID)
+}
+
+func (e *StoryNotFoundError) Is(target error) bool {
+	return target == ErrStoryNotFound
+}
+
+// ForbiddenError is returned when a player isn't allowed to do something in a game. It wraps the reason, e.g.
+// ErrNotFacilitator.
+type ForbiddenError struct {
+	PlayerID PlayerID
+	Reason   error
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("player %d is not allowed to do this: %s", e.PlayerID, e.Reason)
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.Reason
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var ErrUnknownGameExpiry = errors.New("unknown game expiry action")
+
+// GameExpiry is what happens to a game nobody has changed for a while.
+type GameExpiry string
+
+const (
+	GameExpiryArchive GameExpiry = "archive" // the game stays readable, but is not listed and can't be changed
+	GameExpiryDelete  GameExpiry = "delete"  // the game is deleted for good
+)
+
+func (e GameExpiry) validate() error {
+	switch e {
+	case GameExpiryArchive, GameExpiryDelete:
+		return nil
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownGameExpiry, e)
+	}
+}
+
+// ExpireIdleGames archives or deletes games that haven't changed for longer than ttl and returns their IDs.
+// Subscribers of such a game get EventGameExpired and their subscriptions end. Games saved before their activity
+// was tracked get the full ttl from the first call.
+func (d *Dealer) ExpireIdleGames(ttl time.Duration, expiry GameExpiry) ([]GameID, error) {
+	if err := expiry.validate(); err != nil {
+		return nil, err
+	}
+	d.lock.RLock()
+	tables := make([]*table, 0, len(d.games))
+	for _, t := range d.games {
+		tables = append(tables, t)
+	}
+	d.lock.RUnlock()
+
+	var expired []GameID
+	var errs []error
+	for _, t := range tables {
+		ok, err := d.expireIdleGame(t, ttl, expiry)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ok {
+			expired = append(expired, t.poker.ID)
+		}
+	}
+	if len(errs) > 0 {
+		return expired, fmt.Errorf("failed to expire %d games, the first error: %w", len(errs), errs[0])
+	}
+	return expired, nil
+}
+
+func (d *Dealer) expireIdleGame(t *table, ttl time.Duration, expiry GameExpiry) (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	game := t.poker
+	if t.deleted || game.Archived {
+		return false, nil
+	}
+	now := d.clock.Now()
+	if game.LastActivity.IsZero() {
+		game.LastActivity = now
+		return false, nil
+	}
+	if now.Sub(game.LastActivity) <= ttl {
+		return false, nil
+	}
+	switch expiry {
+	case GameExpiryArchive:
+		game.Archived = true
+		if err := t.commit(d.store); err != nil { // not saveGame, the game is not active
+			return false, err
+		}
+		d.cancelTimer(game)
+		d.metrics.gameArchived()
+	case GameExpiryDelete:
+		if err := d.removeGame(game.ID); err != nil {
+			return false, err
+		}
+	}
+	d.log.WithFields(logrus.Fields{
+		"game_id":       game.ID,
+		"last_activity": game.LastActivity,
+		"expiry":        expiry,
+	}).Info("Game expired")
+	d.hub.Publish(NewEvent(EventGameExpired, game.ID, GameExpired{
+		Archived:     expiry == GameExpiryArchive,
+		LastActivity: game.LastActivity,
+	}))
+	d.hub.CloseGame(game.ID)
+	return true, nil
+}
+
+// setClock makes the Dealer take time from clock. It must be called before the Dealer is used.
+func (d *Dealer) setClock(clock Clock) {
+	d.clock = clock
+}
+
+// runJanitor expires idle games every interval until stop is closed. It closes done when it returns.
+func (s *Server) runJanitor(ttl, interval time.Duration, expiry GameExpiry, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.dealer.ExpireIdleGames(ttl, expiry); err != nil {
+				s.log.WithError(err).Error("Failed to expire idle games")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
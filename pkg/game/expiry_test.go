@@ -0,0 +1,193 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testGameTTL = time.Hour
+
+func TestIdleGamesAreArchived(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, expiryConfig(clock, game.GameExpiryArchive))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	idleID := createDefaultGame(t, creator)
+	activeID := createDefaultGame(t, creator)
+	conn := dialGame(t, idleID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+
+	clock.Advance(testGameTTL / 2)
+	vote(t, creator, "5", activeID)
+	clock.Advance(testGameTTL/2 + time.Second)
+
+	event := readEvent(t, conn)
+	require.Equal(t, game.EventGameExpired, event.Type)
+	var expired game.GameExpired
+	require.NoError(t, json.Unmarshal(event.Payload, &expired))
+	require.True(t, expired.Archived)
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+
+	poker := getGame(t, idleID)
+	require.True(t, poker.Archived)
+	require.Equal(t, poker.CreatedAt, poker.LastActivity)
+	games := listGames(t)
+	require.Len(t, games, 1)
+	require.Equal(t, activeID, games[0].ID)
+	voteExpect(t, creator.Token, "5", idleID, http.StatusConflict)
+	require.False(t, getGame(t, activeID).Archived)
+
+	// an archived game can still be watched and deleted
+	conn = dialGame(t, idleID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", idleID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestIdleGamesAreDeleted(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, expiryConfig(clock, game.GameExpiryDelete))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	gameID := createDefaultGame(t, createUser(t))
+
+	clock.Advance(testGameTTL + time.Second)
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fullPath(fmt.Sprintf("/api/games/%d", gameID)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNotFound
+	}, 2*time.Second, 5*time.Millisecond)
+	require.Empty(t, listGames(t))
+}
+
+func TestGameExpiryConfig(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.GameExpiry = "forget"
+	_, err := game.NewServer(cfg)
+	require.ErrorIs(t, err, game.ErrUnknownGameExpiry)
+
+	cfg.GameTTL = 0 // games never expire, so the action doesn't matter
+	srv, err := game.NewServer(cfg)
+	require.NoError(t, err)
+	require.NoError(t, srv.Stop(context.Background()))
+}
+
+func TestExpireIdleGamesInvalidAction(t *testing.T) {
+	_, err := game.NewDealer().ExpireIdleGames(time.Hour, "forget")
+	require.ErrorIs(t, err, game.ErrUnknownGameExpiry)
+}
+
+func expiryConfig(clock game.Clock, expiry game.GameExpiry) game.Config {
+	cfg := game.DefaultConfig()
+	cfg.Clock = clock
+	cfg.GameTTL = testGameTTL
+	cfg.GameExpiry = expiry
+	cfg.JanitorPeriod = time.Millisecond
+	return cfg
+}
+
+func listGames(t *testing.T) []game.GameListEntry {
+	return listGamesQuery(t, "").Games
+}
+
+// listGamesQuery lists games with a query string, e.g. "status=all&limit=2".
+func listGamesQuery(t *testing.T, query string) game.GameListResponse {
+	resp, err := http.Get(fullPath("/api/games?" + query))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list game.GameListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	return list
+}
+
+// fakeClock is a game.Clock that moves only when told to. Its timers and tickers fire as Advance passes them.
+type fakeClock struct {
+	now     time.Time
+	waiters []*fakeTimer
+	lock    sync.Mutex
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.stopped {
+			continue
+		}
+		if !w.fireAt.After(c.now) {
+			select {
+			case w.c <- c.now:
+			default: // like a ticker, drop the tick nobody read
+			}
+			if w.period == 0 {
+				continue
+			}
+			for !w.fireAt.After(c.now) {
+				w.fireAt = w.fireAt.Add(w.period)
+			}
+		}
+		waiters = append(waiters, w)
+	}
+	c.waiters = waiters
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) game.ClockTimer {
+	return c.wait(d, 0)
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) game.ClockTicker {
+	return c.wait(d, d)
+}
+
+func (c *fakeClock) wait(d, period time.Duration) *fakeTimer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &fakeTimer{clock: c, fireAt: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// fakeTimer is a timer of a fakeClock, or a ticker if it has a period.
+type fakeTimer struct {
+	clock   *fakeClock
+	fireAt  time.Time
+	period  time.Duration
+	stopped bool
+	c       chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.stopped = true
+}
@@ -0,0 +1,267 @@
+package game
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a format of exported game results.
+type ExportFormat string
+
+const (
+	ExportCSV      ExportFormat = "csv"
+	ExportJSON     ExportFormat = "json"
+	ExportMarkdown ExportFormat = "md"
+)
+
+// ContentType returns the media type of exported files.
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportMarkdown:
+		return "text/markdown; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// exportColumns is the header of CSV exports. Columns are only ever added at the end, so scripts reading exports
+// keep working.
+var exportColumns = []string{
+	"story_id", "story_key", "story_title", "estimate",
+	"round", "revealed_at", "player_id", "player_name", "vote",
+	"votes", "average", "median", "consensus",
+	"story_link",
+}
+
+// GameExport is the JSON export of a game: its players and completed rounds grouped by story. It holds only results,
+// unlike GameResponse, which also describes the round in progress.
+type GameExport struct {
+	ID         GameID          `json:"id"`
+	Name       string          `json:"name"`
+	Deck       Deck            `json:"deck"`
+	Players    []ExportPlayer  `json:"players"`
+	Stories    []StoryResponse `json:"stories"`
+	Rounds     []Round         `json:"rounds"` // completed rounds that were not about any story
+	ExportedAt time.Time       `json:"exportedAt"`
+}
+
+// ExportPlayer is a player of an exported game.
+type ExportPlayer struct {
+	ID   PlayerID `json:"id"`
+	Name string   `json:"name"`
+	Role Role     `json:"role"`
+}
+
+// Export returns the results of a game in the given format. Archived games can be exported too.
+func (d *Dealer) Export(gameID GameID, format ExportFormat) ([]byte, error) {
+	poker, ok := d.GetGame(gameID)
+	if !ok {
+		return nil, &GameNotFoundError{GameID: gameID}
+	}
+	var b bytes.Buffer
+	if err := WriteExport(&b, NewGameExport(poker, d.clock.Now()), format); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// NewGameExport takes the results out of a game.
+func NewGameExport(poker GameResponse, now time.Time) GameExport {
+	export := GameExport{
+		ID:         poker.ID,
+		Name:       poker.Name,
+		Deck:       poker.Deck,
+		Players:    make([]ExportPlayer, 0, len(poker.Players)),
+		Stories:    poker.Stories,
+		Rounds:     poker.Rounds,
+		ExportedAt: now.UTC(),
+	}
+	for _, player := range poker.Players {
+		export.Players = append(export.Players, ExportPlayer{ID: player.ID, Name: player.Name, Role: player.Role})
+	}
+	return export
+}
+
+// WriteExport writes the export in the given format.
+func WriteExport(w io.Writer, export GameExport, format ExportFormat) error {
+	switch format {
+	case ExportCSV:
+		return writeExportCSV(w, export)
+	case ExportMarkdown:
+		return writeExportMarkdown(w, export)
+	case ExportJSON, "":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// writeExportCSV writes a row per vote with the stats of its round repeated, so the file can be filtered and pivoted
+// as is. Players who skipped a round get a row with an empty vote. Rounds without votes and stories without rounds get
+// a row with empty columns.
+func writeExportCSV(w io.Writer, export GameExport) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(exportColumns); err != nil {
+		return err
+	}
+	writeRounds := func(story Story, rounds []Round) error {
+		storyColumns := []string{"", "", "", ""}
+		if story.ID != 0 {
+			storyColumns = []string{
+				formatUint(uint64(story.ID)),
+				csvCell(story.Key),
+				csvCell(story.Title),
+				csvCell(string(story.Estimate)),
+			}
+		}
+		if len(rounds) == 0 {
+			row := make([]string, len(exportColumns))
+			copy(row, storyColumns)
+			row[len(row)-1] = csvCell(story.Link)
+			return out.Write(row)
+		}
+		for _, round := range rounds {
+			roundColumns := []string{strconv.Itoa(round.Number), round.RevealedAt.UTC().Format(time.RFC3339)}
+			statsColumns := []string{
+				strconv.Itoa(round.Stats.Votes),
+				formatStat(round.Stats.Average),
+				formatStat(round.Stats.Median),
+				strconv.FormatBool(round.Stats.Consensus),
+			}
+			votes := round.allVotes()
+			if len(votes) == 0 {
+				votes = []PlayerRoundVote{{}}
+			}
+			for _, vote := range votes {
+				voteColumns := []string{"", csvCell(vote.PlayerName), csvCell(string(vote.Vote))}
+				if vote.PlayerID != 0 {
+					voteColumns[0] = formatUint(uint64(vote.PlayerID))
+				}
+				row := make([]string, 0, len(exportColumns))
+				row = append(row, storyColumns...)
+				row = append(row, roundColumns...)
+				row = append(row, voteColumns...)
+				row = append(row, statsColumns...)
+				row = append(row, csvCell(story.Link))
+				if err := out.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, story := range export.Stories {
+		if err := writeRounds(story.Story, story.Rounds); err != nil {
+			return err
+		}
+	}
+	if len(export.Rounds) > 0 {
+		if err := writeRounds(Story{}, export.Rounds); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvCell keeps spreadsheets from running text entered by players as a formula: cells starting with a character that
+// starts a formula get a leading apostrophe. Numbers like "-1" are kept, so they stay numbers.
+func csvCell(s string) string {
+	if s == "" || !strings.ContainsAny(s[:1], "=+-@\t\r") {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && (s[0] == '-' || s[0] == '+') {
+		return s
+	}
+	return "'" + s
+}
+
+// writeExportMarkdown writes a report to paste into a tracker or a wiki: players, the backlog with estimates and every
+// round with its votes.
+func writeExportMarkdown(w io.Writer, export GameExport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", escapeMarkdown(export.Name))
+	fmt.Fprintf(&b, "Exported at %s.\n\n", export.ExportedAt.Format(time.RFC3339))
+
+	b.WriteString("## Players\n\n| Name | Role |\n|---|---|\n")
+	for _, player := range export.Players {
+		fmt.Fprintf(&b, "| %s | %s |\n", escapeMarkdown(player.Name), player.Role)
+	}
+
+	if len(export.Stories) > 0 {
+		b.WriteString("\n## Stories\n\n| Key | Title | Estimate | Rounds |\n|---|---|---|---|\n")
+		for _, story := range export.Stories {
+			fmt.Fprintf(&b, "| %s | %s | %s | %d |\n", escapeMarkdown(story.Key), escapeMarkdown(story.Title),
+				escapeMarkdown(string(story.Estimate)), len(story.Rounds))
+		}
+	}
+
+	writeRounds := func(heading string, rounds []Round) {
+		for _, round := range rounds {
+			fmt.Fprintf(&b, "\n### %s, round %d\n\n", heading, round.Number)
+			fmt.Fprintf(&b, "Revealed at %s. %s\n\n", round.RevealedAt.UTC().Format(time.RFC3339),
+				formatStatsSentence(round.Stats))
+			b.WriteString("| Player | Vote |\n|---|---|\n")
+			for _, vote := range round.allVotes() {
+				fmt.Fprintf(&b, "| %s | %s |\n", escapeMarkdown(vote.PlayerName), escapeMarkdown(string(vote.Vote)))
+			}
+		}
+	}
+	for _, story := range export.Stories {
+		heading := story.Title
+		if story.Key != "" {
+			heading = story.Key + " " + heading
+		}
+		writeRounds(escapeMarkdown(heading), story.Rounds)
+	}
+	writeRounds("Without a story", export.Rounds)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatStatsSentence(stats RoundStats) string {
+	parts := []string{fmt.Sprintf("Votes: %d", stats.Votes)}
+	if stats.Average != nil {
+		parts = append(parts, "average: "+formatStat(stats.Average))
+	}
+	if stats.Median != nil {
+		parts = append(parts, "median: "+formatStat(stats.Median))
+	}
+	if stats.Consensus {
+		parts = append(parts, "consensus")
+	}
+	return strings.Join(parts, ", ") + "."
+}
+
+// markdownEscaper escapes characters that would end a table cell or start formatting. Line breaks would end the row,
+// so they are replaced with spaces.
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`", "[", "\\[", "]", "\\]", "<", "&lt;", ">", "&gt;",
+	"#", "\\#", "\r\n", " ", "\n", " ", "\r", " ",
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func formatStat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
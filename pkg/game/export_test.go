@@ -0,0 +1,147 @@
+package game_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	idle := createUser(t) // never votes
+	join(t, idle, gameID)
+	joinAs(t, createUser(t).Token, gameID, game.RoleObserver, http.StatusOK)
+
+	// a round without a story, one with a story and a story that wasn't estimated yet
+	vote(t, creator, "5", gameID)
+	vote(t, voter, "8", gameID)
+	reveal(t, creator.Token, gameID)
+	story := addStory(t, creator.Token, gameID, game.StoryRequest{
+		Key:   "PRJ-1",
+		Title: "Login, \"SSO\" | page\nfor *admins*",
+		Link:  "https://tracker.example.com/PRJ-1",
+	})
+	newRound(t, creator.Token, gameID)
+	vote(t, creator, "3", gameID)
+	vote(t, voter, "3", gameID)
+	reveal(t, creator.Token, gameID)
+	setEstimate(t, creator.Token, gameID, story.ID, "3")
+	addStory(t, creator.Token, gameID, game.StoryRequest{Title: "Later"})
+	join(t, createUser(t), gameID) // didn't play the rounds, so didn't skip them
+
+	t.Run("csv", func(t *testing.T) {
+		body := export(t, gameID, "csv", "text/csv; charset=utf-8")
+		rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"story_id", "story_key", "story_title", "estimate",
+			"round", "revealed_at", "player_id", "player_name", "vote",
+			"votes", "average", "median", "consensus",
+			"story_link",
+		}, rows[0])
+		require.Len(t, rows, 8)
+		votes := map[string]string{}
+		for _, row := range rows[1:4] {
+			require.Equal(t, []string{"1", "PRJ-1", story.Title, "3", "1"}, row[:5])
+			require.Equal(t, []string{"2", "3", "3", "true", "https://tracker.example.com/PRJ-1"}, row[9:])
+			votes[row[7]] = row[8]
+		}
+		require.Equal(t, map[string]string{creator.Name: "3", voter.Name: "3", idle.Name: ""}, votes)
+		require.Equal(t, []string{"2", "", "Later", "", "", "", "", "", "", "", "", "", "", ""}, rows[4])
+		votes = map[string]string{}
+		for _, row := range rows[5:] {
+			require.Equal(t, []string{"", "", "", "", "1"}, row[:5])
+			require.Equal(t, []string{"2", "6.5", "6.5", "false", ""}, row[9:])
+			votes[row[7]] = row[8]
+		}
+		require.Equal(t, map[string]string{creator.Name: "5", voter.Name: "8", idle.Name: ""}, votes)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var exported game.GameExport
+		require.NoError(t, json.Unmarshal([]byte(export(t, gameID, "json", "application/json; charset=utf-8")), &exported))
+		require.Equal(t, gameID, exported.ID)
+		require.Len(t, exported.Players, 5)
+		require.Len(t, exported.Stories, 2)
+		require.Equal(t, game.Vote("3"), exported.Stories[0].Estimate)
+		require.Len(t, exported.Stories[0].Rounds, 1)
+		require.Empty(t, exported.Stories[1].Rounds)
+		require.Len(t, exported.Rounds, 1)
+		require.Equal(t, []game.PlayerRoundVote{{PlayerID: idle.ID, PlayerName: idle.Name}}, exported.Rounds[0].Skipped)
+		require.False(t, exported.ExportedAt.IsZero())
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		body := export(t, gameID, "md", "text/markdown; charset=utf-8")
+		require.Contains(t, body, "| Name | Role |\n|---|---|\n")
+		require.Contains(t, body, "| PRJ-1 | Login, \"SSO\" \\| page for \\*admins\\* | 3 | 1 |\n")
+		require.Contains(t, body, "### PRJ-1 Login, \"SSO\" \\| page for \\*admins\\*, round 1\n")
+		require.Contains(t, body, "Votes: 2, average: 3, median: 3, consensus.")
+		require.Contains(t, body, "### Without a story, round 1\n")
+		require.Contains(t, body, fmt.Sprintf("| %s | 8 |\n", voter.Name))
+		require.Contains(t, body, fmt.Sprintf("| %s |  |\n", idle.Name))
+	})
+
+	resp, err := http.Get(fullPath(fmt.Sprintf("/api/games/%d/export?format=xlsx", gameID)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Get(fullPath("/api/games/100/export"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestExportCSVFormulas(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	addStory(t, creator.Token, gameID, game.StoryRequest{Key: "@SUM(A1)", Title: "=HYPERLINK(\"https://evil.example.com\")"})
+	addStory(t, creator.Token, gameID, game.StoryRequest{Key: "-2+3", Title: "+SUM(A1)"})
+	addStory(t, creator.Token, gameID, game.StoryRequest{Key: "\tkey", Title: "\rtitle"})
+	addStory(t, creator.Token, gameID, game.StoryRequest{Key: "a=b", Title: "1+1"})
+	addStory(t, creator.Token, gameID, game.StoryRequest{Key: "-1", Title: "+2.5"}) // numbers stay numbers
+
+	rows, err := csv.NewReader(strings.NewReader(export(t, gameID, "csv", "text/csv; charset=utf-8"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	var cells [][]string
+	for _, row := range rows[1:] {
+		cells = append(cells, row[1:3])
+	}
+	require.Equal(t, [][]string{
+		{"'@SUM(A1)", "'=HYPERLINK(\"https://evil.example.com\")"},
+		{"'-2+3", "'+SUM(A1)"},
+		{"'\tkey", "'\rtitle"},
+		{"a=b", "1+1"},
+		{"-1", "+2.5"},
+	}, cells)
+}
+
+func export(t *testing.T, gameID game.GameID, format, contentType string) string {
+	resp, err := http.Get(fullPath(fmt.Sprintf("/api/games/%d/export?format=%s", gameID, format)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, contentType, resp.Header.Get("Content-Type"))
+	require.Equal(t, fmt.Sprintf(`attachment; filename="game-%d.%s"`, gameID, format),
+		resp.Header.Get("Content-Disposition"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
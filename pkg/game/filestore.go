@@ -0,0 +1,327 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type fileOp string
+
+const (
+	opSaveGame      fileOp = "save_game"
+	opDeleteGame    fileOp = "delete_game"
+	opNextGameID    fileOp = "next_game_id"
+	opSavePlayer    fileOp = "save_player"
+	opNextPlayerID  fileOp = "next_player_id"
+	opSaveSession   fileOp = "save_session"
+	opDeleteSession fileOp = "delete_session"
+)
+
+// compactionSuffix is added to the log path to get a file where the compacted log is written before replacing the log.
+const compactionSuffix = ".tmp"
+
+// minCompactionRecords is how many records are appended to the log at least before it's compacted again.
+const minCompactionRecords = 1000
+
+// fileRecord is a single line of FileStore log. Which fields are set depends on Op.
+type fileRecord struct {
+	Op       fileOp   `json:"op"`
+	Game     *Poker   `json:"game,omitempty"`
+	GameID   GameID   `json:"gameId,omitempty"`
+	Player   *Player  `json:"player,omitempty"`
+	PlayerID PlayerID `json:"playerId,omitempty"`
+
+	Session   *SavedSession `json:"session,omitempty"`
+	SessionID string        `json:"sessionId,omitempty"`
+}
+
+// FileStore keeps games, players and sessions in an append-only log of JSON records, one per line. Every record is
+// synced to disk before a save returns. The log is replayed and compacted when the store is opened, and compacted again
+// whenever as many records were appended as the compaction left, but at least minCompactionRecords, so it stays
+// within a few times the size of the state.
+type FileStore struct {
+	path string
+	file *os.File
+	lock sync.Mutex // protects file, the state and the counts of records
+
+	// state as the log describes it, records are applied to it as they are written
+	games        map[GameID]*Poker
+	nextGameID   GameID
+	players      map[PlayerID]Player
+	nextPlayerID PlayerID
+	sessions     map[string]SavedSession
+
+	records   int // in the log
+	compacted int // records left by the last compaction
+}
+
+// OpenFileStore opens or creates a log at path.
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{
+		path:         path,
+		games:        map[GameID]*Poker{},
+		nextGameID:   1,
+		players:      map[PlayerID]Player{},
+		nextPlayerID: 1,
+		sessions:     map[string]SavedSession{},
+	}
+	if err := store.replay(path); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := store.compact(); err != nil {
+		return nil, fmt.Errorf("failed to compact %s: %w", path, err)
+	}
+	return store, nil
+}
+
+func (s *FileStore) replay(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without a newline at the end is a record which wasn't fully written, e.g. because of a crash.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record fileRecord
+		if err = json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if err = s.apply(record); err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+}
+
+func (s *FileStore) apply(record fileRecord) error {
+	switch record.Op {
+	case opSaveGame:
+		if record.Game == nil {
+			return errors.New("game is missing")
+		}
+		s.games[record.Game.ID] = record.Game
+	case opDeleteGame:
+		delete(s.games, record.GameID)
+	case opNextGameID:
+		s.nextGameID = record.GameID
+	case opSavePlayer:
+		if record.Player == nil {
+			return errors.New("player is missing")
+		}
+		s.players[record.Player.ID] = *record.Player
+	case opNextPlayerID:
+		s.nextPlayerID = record.PlayerID
+	case opSaveSession:
+		if record.Session == nil {
+			return errors.New("session is missing")
+		}
+		s.sessions[record.Session.ID] = *record.Session
+	case opDeleteSession:
+		delete(s.sessions, record.SessionID)
+	default:
+		return fmt.Errorf("unknown operation %q", record.Op)
+	}
+	return nil
+}
+
+// compact replaces the log with the minimal set of records describing the current state and opens it for appending.
+// Expired sessions are dropped.
+func (s *FileStore) compact() error {
+	tmpPath := s.path + compactionSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath) // does nothing after successful rename
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	records := []fileRecord{
+		{Op: opNextGameID, GameID: s.nextGameID},
+		{Op: opNextPlayerID, PlayerID: s.nextPlayerID},
+	}
+	for _, player := range sortedPlayers(s.players) {
+		player := player
+		records = append(records, fileRecord{Op: opSavePlayer, Player: &player})
+	}
+	for _, poker := range sortedGames(s.games) {
+		records = append(records, fileRecord{Op: opSaveGame, Game: poker})
+	}
+	now := time.Now()
+	for _, session := range sortedSessions(s.sessions) {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, session.ID)
+			continue
+		}
+		session := session
+		records = append(records, fileRecord{Op: opSaveSession, Session: &session})
+	}
+	for _, record := range records {
+		if err = encoder.Encode(&record); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	s.file = file
+	s.records, s.compacted = len(records), len(records)
+	return nil
+}
+
+func (s *FileStore) write(record fileRecord) error {
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+	// the record applied to the state is decoded from the line, so it shares nothing with the caller
+	var saved fileRecord
+	if err = json.Unmarshal(line, &saved); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = s.file.Sync(); err != nil {
+		return err
+	}
+	if err = s.apply(saved); err != nil {
+		return err
+	}
+	s.records++
+	appended := s.records - s.compacted
+	if appended >= minCompactionRecords && appended >= s.compacted {
+		// the record is saved already, a failed compaction is tried again with the next one
+		_ = s.compact()
+	}
+	return nil
+}
+
+// LoadGames returns games as they were when the store was opened, or as they are saved now if it's called later.
+func (s *FileStore) LoadGames() ([]*Poker, GameID, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	games := sortedGames(s.games)
+	for i, poker := range games { // the caller changes its games, while the store keeps writing them on compaction
+		games[i] = poker.clone()
+	}
+	return games, s.nextGameID, nil
+}
+
+func (s *FileStore) SaveGame(poker *Poker) error {
+	return s.write(fileRecord{Op: opSaveGame, Game: poker})
+}
+
+func (s *FileStore) DeleteGame(id GameID) error {
+	return s.write(fileRecord{Op: opDeleteGame, GameID: id})
+}
+
+func (s *FileStore) SaveNextGameID(id GameID) error {
+	return s.write(fileRecord{Op: opNextGameID, GameID: id})
+}
+
+// LoadPlayers returns players as they were when the store was opened, or as they are saved now if it's called later.
+func (s *FileStore) LoadPlayers() ([]Player, PlayerID, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return sortedPlayers(s.players), s.nextPlayerID, nil
+}
+
+func (s *FileStore) SavePlayer(player Player) error {
+	return s.write(fileRecord{Op: opSavePlayer, Player: &player})
+}
+
+func (s *FileStore) SaveNextPlayerID(id PlayerID) error {
+	return s.write(fileRecord{Op: opNextPlayerID, PlayerID: id})
+}
+
+// LoadSessions returns sessions as they were when the store was opened, or as they are saved now if it's called later,
+// except those that had expired by the last compaction.
+func (s *FileStore) LoadSessions() ([]SavedSession, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return sortedSessions(s.sessions), nil
+}
+
+func (s *FileStore) SaveSession(session SavedSession) error {
+	return s.write(fileRecord{Op: opSaveSession, Session: &session})
+}
+
+func (s *FileStore) DeleteSession(id string) error {
+	return s.write(fileRecord{Op: opDeleteSession, SessionID: id})
+}
+
+// Close closes the log file.
+func (s *FileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
+
+func sortedGames(games map[GameID]*Poker) []*Poker {
+	sorted := make([]*Poker, 0, len(games))
+	for _, poker := range games {
+		sorted = append(sorted, poker)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+func sortedPlayers(players map[PlayerID]Player) []Player {
+	sorted := make([]Player, 0, len(players))
+	for _, player := range players {
+		sorted = append(sorted, player)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+func sortedSessions(sessions map[string]SavedSession) []SavedSession {
+	sorted := make([]SavedSession, 0, len(sessions))
+	for _, session := range sessions {
+		sorted = append(sorted, session)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
@@ -0,0 +1,193 @@
+package game_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	registry, err := game.NewPlayerRegistryWithStore(store)
+	require.NoError(t, err)
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+
+	creator, err := registry.Register("bobby")
+	require.NoError(t, err)
+	voter, err := registry.Register("alice")
+	require.NoError(t, err)
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	first, err := dealer.CreateGame("first", creator, deck)
+	require.NoError(t, err)
+	second, err := dealer.CreateGame("second", creator, deck)
+	require.NoError(t, err)
+	require.NoError(t, dealer.JoinGame(first.ID, voter, game.RoleVoter))
+	require.NoError(t, dealer.Vote(first.ID, voter.ID, "5"))
+	require.NoError(t, store.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	registry, err = game.NewPlayerRegistryWithStore(store)
+	require.NoError(t, err)
+	dealer, err = game.NewDealerWithStore(store)
+	require.NoError(t, err)
+
+	player, ok := registry.Get(voter.ID)
+	require.True(t, ok)
+	require.Equal(t, voter, player)
+	next, err := registry.Register("carol")
+	require.NoError(t, err)
+	require.Equal(t, voter.ID+1, next.ID) // counter continues
+
+	loaded, ok := dealer.GetGame(first.ID)
+	require.True(t, ok)
+	require.Len(t, loaded.Players, 2)
+	revealed, err := dealer.Reveal(first.ID, creator.ID) // roles are restored too
+	require.NoError(t, err)
+	require.Equal(t, 1, revealed.Stats.Votes)
+	_, ok = dealer.GetGame(second.ID)
+	require.True(t, ok)
+	third, err := dealer.CreateGame("third", creator, deck)
+	require.NoError(t, err)
+	require.Equal(t, second.ID+1, third.ID)
+}
+
+func TestFileStoreIgnoresUnfinishedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.SavePlayer(game.Player{ID: 1, Name: "bobby"}))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"save_player","player":{"id":2,"na`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	players, _, err := store.LoadPlayers()
+	require.NoError(t, err)
+	require.Equal(t, []game.Player{{ID: 1, Name: "bobby"}}, players)
+}
+
+func TestFileStoreCompactsWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.SavePlayer(game.Player{ID: 1, Name: "bobby"}))
+	poker := &game.Poker{ID: 1, Name: "sprint"}
+	for i := 0; i < 2500; i++ {
+		poker.Name = fmt.Sprintf("sprint %d", i)
+		require.NoError(t, store.SaveGame(poker))
+	}
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Less(t, strings.Count(string(content), "\n"), 1000)
+	require.NoError(t, store.SaveGame(poker)) // still appends to the compacted log
+	require.NoError(t, store.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	games, _, err := store.LoadGames()
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	require.Equal(t, "sprint 2499", games[0].Name)
+	players, _, err := store.LoadPlayers()
+	require.NoError(t, err)
+	require.Equal(t, []game.Player{{ID: 1, Name: "bobby"}}, players)
+}
+
+func TestFileStoreCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	require.NoError(t, os.WriteFile(path, []byte("{\"op\":\"unknown\"}\n"), 0o600))
+	_, err := game.OpenFileStore(path)
+	require.Error(t, err)
+}
+
+func TestOpenStoreErrors(t *testing.T) {
+	_, err := game.OpenStore(game.StorageConfig{Backend: "tape"})
+	require.ErrorIs(t, err, game.ErrUnknownStorage)
+	_, err = game.OpenStore(game.StorageConfig{Backend: game.StorageFile})
+	require.Error(t, err)
+}
+
+func TestServerWithFileStorage(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.Storage = game.StorageConfig{Backend: game.StorageFile, Path: filepath.Join(t.TempDir(), "gpoker.log")}
+	_, err := game.NewServer(cfg)
+	require.Error(t, err)
+	cfg.AuthSecret = "s3cret"
+	srv := startServerWithConfig(t, cfg)
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	resp := doJSON(t, voter.Token, http.MethodPost, "/api/logout", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NoError(t, srv.Stop(context.Background()))
+
+	srv = startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	poker := getGame(t, gameID)
+	require.Equal(t, []game.PlayerResponse{{ID: creator.ID, Name: creator.Name, Role: game.RoleFacilitator}}, poker.Players)
+	// sessions are saved too, so the facilitator still manages the game, but revoked sessions stay revoked
+	rename := game.RenameGameRequest{Name: "renamed"}
+	resp = doJSON(t, creator.Token, http.MethodPut, fmt.Sprintf("/api/games/%d", gameID), rename)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	joinExpect(t, voter.Token, gameID, http.StatusUnauthorized)
+	join(t, createUser(t), gameID)
+}
+
+func TestFileStoreSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	sessions, err := game.NewSessionsWithStore([]byte("secret"), time.Hour, store)
+	require.NoError(t, err)
+	kept, _, err := sessions.Issue(1)
+	require.NoError(t, err)
+	revoked, _, err := sessions.Issue(2)
+	require.NoError(t, err)
+	require.NoError(t, sessions.Revoke(revoked))
+	require.NoError(t, store.SaveSession(game.SavedSession{ID: "expired", PlayerID: 3, ExpiresAt: time.Now()}))
+	require.NoError(t, store.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	saved, err := store.LoadSessions()
+	require.NoError(t, err)
+	require.Len(t, saved, 1) // expired sessions are dropped by compaction
+	require.Equal(t, game.PlayerID(1), saved[0].PlayerID)
+	require.NotContains(t, saved[0].ID, strings.Split(kept, ".")[0])
+
+	sessions, err = game.NewSessionsWithStore([]byte("secret"), time.Hour, store)
+	require.NoError(t, err)
+	playerID, err := sessions.Resolve(kept)
+	require.NoError(t, err)
+	require.Equal(t, game.PlayerID(1), playerID)
+	_, err = sessions.Resolve(revoked)
+	require.ErrorIs(t, err, game.ErrInvalidToken)
+	// the same session signed with another secret is not valid
+	sessions, err = game.NewSessionsWithStore([]byte("other"), time.Hour, store)
+	require.NoError(t, err)
+	_, err = sessions.Resolve(kept)
+	require.ErrorIs(t, err, game.ErrInvalidToken)
+}
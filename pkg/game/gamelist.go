@@ -0,0 +1,201 @@
+package game
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// GameStatus selects games by whether they are archived.
+type GameStatus string
+
+const (
+	GameStatusActive   GameStatus = "active"
+	GameStatusArchived GameStatus = "archived"
+	GameStatusAll      GameStatus = "all"
+)
+
+// GameSort is an order of games in a list. Orders with the "-" prefix are descending. Games with equal keys are
+// ordered by ID.
+type GameSort string
+
+const (
+	GameSortName     GameSort = "name"
+	GameSortCreated  GameSort = "created"
+	GameSortActivity GameSort = "activity" // by LastActivity
+)
+
+const (
+	DefaultGameListLimit = 50
+	MaxGameListLimit     = 200 // larger limits are rejected by the API and lowered by Dealer.ListGames
+)
+
+// gameListCursor is the position after the last game of a page. Pages are found by the sort key rather than an
+// offset, so games created or deleted in the meantime don't shift them.
+type gameListCursor struct {
+	Sort GameSort   `json:"s"`
+	ID   GameID     `json:"i"`
+	Name string     `json:"n,omitempty"`
+	Time *time.Time `json:"t,omitempty"` // CreatedAt or LastActivity, depending on Sort
+}
+
+// ListGames returns a page of games matching req, see ListGamesRequest. ErrInvalidCursor is returned if the cursor is
+// malformed or was made for another sort order.
+func (d *Dealer) ListGames(req ListGamesRequest) (GameListResponse, error) {
+	if req.Status == "" {
+		req.Status = GameStatusActive
+	}
+	if req.Sort == "" {
+		req.Sort = GameSortName
+	}
+	if req.Limit <= 0 {
+		req.Limit = DefaultGameListLimit
+	}
+	if req.Limit > MaxGameListLimit {
+		req.Limit = MaxGameListLimit
+	}
+	compare := gameComparator(req.Sort)
+
+	d.lock.RLock()
+	tables := make([]*table, 0, len(d.games))
+	for _, t := range d.games {
+		tables = append(tables, t)
+	}
+	d.lock.RUnlock()
+	games := make([]GameListEntry, 0, len(tables))
+	for _, t := range tables {
+		t.lock.RLock()
+		if !t.deleted && req.matches(t.poker) {
+			games = append(games, gameToListEntry(t.poker))
+		}
+		t.lock.RUnlock()
+	}
+	sort.Slice(games, func(i, j int) bool { return compare(games[i], games[j]) < 0 })
+
+	if req.Cursor != "" {
+		after, err := decodeGameListCursor(req.Cursor, req.Sort)
+		if err != nil {
+			return GameListResponse{}, err
+		}
+		start := sort.Search(len(games), func(i int) bool { return compare(games[i], after) > 0 })
+		games = games[start:]
+	}
+	resp := GameListResponse{Games: games}
+	if len(games) > req.Limit {
+		resp.Games = games[:req.Limit]
+		resp.NextCursor = encodeGameListCursor(resp.Games[req.Limit-1], req.Sort)
+	}
+	return resp, nil
+}
+
+func (req ListGamesRequest) matches(poker *Poker) bool {
+	switch {
+	case req.Status == GameStatusActive && poker.Archived, req.Status == GameStatusArchived && !poker.Archived:
+		return false
+	case req.Name != "" && !strings.Contains(strings.ToLower(poker.Name), strings.ToLower(req.Name)):
+		return false
+	case req.CreatedBy != 0 && poker.CreatedBy != req.CreatedBy:
+		return false
+	}
+	if req.Member != 0 {
+		_, ok := poker.Players[req.Member]
+		return ok
+	}
+	return true
+}
+
+func gameToListEntry(poker *Poker) GameListEntry {
+	return GameListEntry{
+		ID:           poker.ID,
+		Name:         poker.Name,
+		PlayerCount:  len(poker.Players),
+		CreatedBy:    poker.CreatedBy,
+		CreatedAt:    poker.CreatedAt,
+		LastActivity: poker.LastActivity,
+		State:        poker.State,
+		Archived:     poker.Archived,
+	}
+}
+
+// gameComparator returns a function that compares games in order. It returns a negative number if a goes
+// before b and a positive one if a goes after b.
+func gameComparator(order GameSort) func(a, b GameListEntry) int {
+	descending := strings.HasPrefix(string(order), "-")
+	var compareKeys func(a, b GameListEntry) int
+	switch GameSort(strings.TrimPrefix(string(order), "-")) {
+	case GameSortCreated:
+		compareKeys = func(a, b GameListEntry) int { return compareTimes(a.CreatedAt, b.CreatedAt) }
+	case GameSortActivity:
+		compareKeys = func(a, b GameListEntry) int { return compareTimes(a.LastActivity, b.LastActivity) }
+	default:
+		compareKeys = func(a, b GameListEntry) int { return strings.Compare(a.Name, b.Name) }
+	}
+	return func(a, b GameListEntry) int {
+		c := compareKeys(a, b)
+		if descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		switch {
+		case a.ID < b.ID:
+			return -1
+		case a.ID > b.ID:
+			return 1
+		default:
+			return 0
+		}
+	}
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func encodeGameListCursor(last GameListEntry, order GameSort) string {
+	cursor := gameListCursor{Sort: order, ID: last.ID}
+	switch GameSort(strings.TrimPrefix(string(order), "-")) {
+	case GameSortCreated:
+		cursor.Time = &last.CreatedAt
+	case GameSortActivity:
+		cursor.Time = &last.LastActivity
+	default:
+		cursor.Name = last.Name
+	}
+	encoded, _ := json.Marshal(cursor) // can't fail, the cursor has only plain fields
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeGameListCursor returns the last game of the previous page with only the fields of the sort key set.
+func decodeGameListCursor(encoded string, order GameSort) (GameListEntry, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return GameListEntry{}, ErrInvalidCursor
+	}
+	var cursor gameListCursor
+	if err = json.Unmarshal(decoded, &cursor); err != nil {
+		return GameListEntry{}, ErrInvalidCursor
+	}
+	if cursor.Sort != order {
+		return GameListEntry{}, fmt.Errorf("%w: it was made for sort %q", ErrInvalidCursor, cursor.Sort)
+	}
+	after := GameListEntry{ID: cursor.ID, Name: cursor.Name}
+	if cursor.Time != nil {
+		after.CreatedAt, after.LastActivity = *cursor.Time, *cursor.Time
+	}
+	return after, nil
+}
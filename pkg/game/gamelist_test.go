@@ -0,0 +1,129 @@
+package game_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestListGamesPages(t *testing.T) {
+	clock := newFakeClock()
+	cfg := game.DefaultConfig()
+	cfg.Clock = clock
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	names := []string{"delta", "alpha", "echo", "charlie", "bravo"}
+	ids := map[string]game.GameID{}
+	for _, name := range names {
+		ids[name] = createGame(t, creator.Token, game.CreatePokerRequest{GameName: name})
+		clock.Advance(time.Minute)
+	}
+
+	tests := []struct {
+		sort     game.GameSort
+		expected []string
+	}{
+		{sort: "", expected: []string{"alpha", "bravo", "charlie", "delta", "echo"}},
+		{sort: "-name", expected: []string{"echo", "delta", "charlie", "bravo", "alpha"}},
+		{sort: "created", expected: names},
+		{sort: "-created", expected: []string{"bravo", "charlie", "echo", "alpha", "delta"}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("sort %q", test.sort), func(t *testing.T) {
+			var (
+				listed []string
+				cursor string
+				pages  int
+			)
+			for {
+				page := listGamesQuery(t, fmt.Sprintf("sort=%s&limit=2&cursor=%s", test.sort, cursor))
+				pages++
+				require.LessOrEqual(t, len(page.Games), 2)
+				for _, entry := range page.Games {
+					listed = append(listed, entry.Name)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+			require.Equal(t, test.expected, listed)
+			require.Equal(t, 3, pages)
+		})
+	}
+
+	// pages don't shift when games before the cursor are deleted
+	first := listGamesQuery(t, "limit=2")
+	require.Len(t, first.Games, 2)
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", ids["alpha"]), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	second := listGamesQuery(t, "limit=2&cursor="+first.NextCursor)
+	require.Equal(t, ids["charlie"], second.Games[0].ID)
+
+	// activity is updated by every change
+	vote(t, creator, "5", ids["delta"])
+	byActivity := listGamesQuery(t, "sort=-activity&limit=1")
+	require.Equal(t, ids["delta"], byActivity.Games[0].ID)
+	require.Equal(t, clock.Now(), byActivity.Games[0].LastActivity.UTC())
+
+	// a cursor is valid only for the order it was made for
+	resp = doRaw(t, "", http.MethodGet, "/api/games?sort=created&cursor="+first.NextCursor, "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestListGamesFilters(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, expiryConfig(clock, game.GameExpiryArchive))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	alice := createUser(t)
+	bob := createUser(t)
+	oldID := createGame(t, alice.Token, game.CreatePokerRequest{GameName: "Old Sprint"})
+	clock.Advance(testGameTTL + time.Second)
+	require.Eventually(t, func() bool { return getGame(t, oldID).Archived }, 2*time.Second, 5*time.Millisecond)
+	sprintID := createGame(t, alice.Token, game.CreatePokerRequest{GameName: "Sprint 42"})
+	planningID := createGame(t, bob.Token, game.CreatePokerRequest{GameName: "Planning"})
+	join(t, alice, planningID)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []game.GameID
+	}{
+		{name: "active by default", query: "", expected: []game.GameID{planningID, sprintID}},
+		{name: "archived", query: "status=archived", expected: []game.GameID{oldID}},
+		{name: "all", query: "status=all", expected: []game.GameID{oldID, planningID, sprintID}},
+		{name: "name", query: "status=all&name=sPrInT", expected: []game.GameID{oldID, sprintID}},
+		{name: "created by", query: fmt.Sprintf("createdBy=%d", bob.ID), expected: []game.GameID{planningID}},
+		{name: "member", query: fmt.Sprintf("member=%d", alice.ID), expected: []game.GameID{planningID, sprintID}},
+		{name: "combined", query: fmt.Sprintf("member=%d&createdBy=%d", bob.ID, alice.ID), expected: []game.GameID{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := listGamesQuery(t, test.query)
+			listed := make([]game.GameID, 0, len(list.Games))
+			for _, entry := range list.Games {
+				listed = append(listed, entry.ID)
+			}
+			require.Equal(t, test.expected, listed)
+		})
+	}
+
+	planning := listGamesQuery(t, "createdBy="+fmt.Sprint(bob.ID)).Games[0]
+	require.Equal(t, game.GameListEntry{
+		ID:           planningID,
+		Name:         "Planning",
+		PlayerCount:  2,
+		CreatedBy:    bob.ID,
+		CreatedAt:    planning.CreatedAt,
+		LastActivity: planning.LastActivity,
+		State:        game.RoundVoting,
+	}, planning)
+	require.Equal(t, clock.Now(), planning.CreatedAt.UTC())
+}
@@ -0,0 +1,268 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var errNoRoute = errors.New("no such endpoint")
+
+// ErrorCode tells clients what went wrong in a machine-readable way.
+type ErrorCode string
+
+const (
+	CodeBadRequest       ErrorCode = "bad_request"       // e.g. a malformed ID in the path
+	CodeMalformedBody    ErrorCode = "malformed_body"    // the body is not valid JSON or is missing
+	CodeValidationFailed ErrorCode = "validation_failed" // see details for the fields
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeNotFound         ErrorCode = "not_found" // no such endpoint
+	CodeGameNotFound     ErrorCode = "game_not_found"
+	CodePlayerNotFound   ErrorCode = "player_not_found"
+	CodeNotInGame        ErrorCode = "not_in_game"
+	CodeStoryNotFound    ErrorCode = "story_not_found"
+	CodeInvalidVote      ErrorCode = "invalid_vote"
+	CodeInvalidDeck      ErrorCode = "invalid_deck"
+	CodeInvalidRole      ErrorCode = "invalid_role"
+	CodeInvalidOrder     ErrorCode = "invalid_story_order"
+	CodeVotingClosed     ErrorCode = "voting_closed"
+	CodeNoNextStory      ErrorCode = "no_next_story"
+	CodeGameArchived     ErrorCode = "game_archived"
+	CodeJoinCodeNotFound ErrorCode = "join_code_not_found"
+	CodeInvalidCursor    ErrorCode = "invalid_cursor"
+	CodeInvalidImport    ErrorCode = "invalid_import" // the file can't be read, details list invalid rows if any
+	CodeUnsupportedType  ErrorCode = "unsupported_media_type"
+	CodeImportTooLarge   ErrorCode = "import_too_large"
+	CodeWebhookNotFound  ErrorCode = "webhook_not_found"
+	CodeInvalidWebhook   ErrorCode = "invalid_webhook"
+	CodeWebhooksDisabled ErrorCode = "webhooks_disabled" // the server doesn't allow webhooks of games
+	CodeTooManyWebhooks  ErrorCode = "too_many_webhooks"
+	CodeUnavailable      ErrorCode = "unavailable" // the server is shutting down
+	CodeInternal         ErrorCode = "internal"
+)
+
+// errorStatuses maps errors to HTTP statuses and codes. The first entry the error matches with errors.Is wins.
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   ErrorCode
+}{
+	{errNoRoute, http.StatusNotFound, CodeNotFound},
+	{ErrBadGameID, http.StatusBadRequest, CodeBadRequest},
+	{ErrBadStoryID, http.StatusBadRequest, CodeBadRequest},
+	{ErrBadPlayerID, http.StatusBadRequest, CodeBadRequest},
+	{ErrBadWebhookID, http.StatusBadRequest, CodeBadRequest},
+	{ErrInvalidCursor, http.StatusBadRequest, CodeInvalidCursor},
+	{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, CodeUnsupportedType},
+	{ErrImportTooLarge, http.StatusRequestEntityTooLarge, CodeImportTooLarge},
+	{ErrInvalidToken, http.StatusUnauthorized, CodeUnauthorized},
+	{ErrNotFacilitator, http.StatusForbidden, CodeForbidden},
+	{ErrNotAdmin, http.StatusForbidden, CodeForbidden},
+	{ErrWebhooksDisabled, http.StatusForbidden, CodeWebhooksDisabled},
+	{ErrObserverCannotVote, http.StatusForbidden, CodeForbidden},
+	{ErrGameNotFound, http.StatusNotFound, CodeGameNotFound},
+	{ErrJoinCodeNotFound, http.StatusNotFound, CodeJoinCodeNotFound},
+	{ErrPlayerNotFound, http.StatusNotFound, CodePlayerNotFound},
+	{ErrPlayerNotInGame, http.StatusNotFound, CodeNotInGame},
+	{ErrStoryNotFound, http.StatusNotFound, CodeStoryNotFound},
+	{ErrWebhookNotFound, http.StatusNotFound, CodeWebhookNotFound},
+	{ErrInvalidDeck, http.StatusBadRequest, CodeInvalidDeck},
+	{ErrInvalidRole, http.StatusBadRequest, CodeInvalidRole},
+	{ErrInvalidStoryOrder, http.StatusBadRequest, CodeInvalidOrder},
+	{ErrInvalidWebhook, http.StatusBadRequest, CodeInvalidWebhook},
+	{ErrVotingClosed, http.StatusConflict, CodeVotingClosed},
+	{ErrNoNextStory, http.StatusConflict, CodeNoNextStory},
+	{ErrGameArchived, http.StatusConflict, CodeGameArchived},
+	{ErrTooManyWebhooks, http.StatusConflict, CodeTooManyWebhooks},
+	{ErrDealerClosed, http.StatusServiceUnavailable, CodeUnavailable},
+}
+
+// bindingError is an error of decoding or validating a request body or, if query is set, a query string.
+type bindingError struct {
+	err   error
+	query bool
+}
+
+func (e *bindingError) Error() string { return e.err.Error() }
+func (e *bindingError) Unwrap() error { return e.err }
+
+// bindJSON decodes the request body into obj and validates it. Errors are reported with abortWithError.
+func bindJSON(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		abortWithError(c, &bindingError{err: err})
+		return false
+	}
+	return true
+}
+
+// bindQuery is like bindJSON, but reads obj from the query string.
+func bindQuery(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		abortWithError(c, &bindingError{err: err, query: true})
+		return false
+	}
+	return true
+}
+
+// abortWithError answers with an ErrorResponse matching err and stops the request. Internal errors are logged, but
+// not shown to clients.
+func abortWithError(c *gin.Context, err error) {
+	status, resp := errorToResponse(err)
+	resp.RequestID = requestID(c)
+	if status == http.StatusInternalServerError {
+		logger(c).WithError(err).Error("Internal error")
+	}
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(status, &resp)
+}
+
+func errorToResponse(err error) (int, ErrorResponse) {
+	var (
+		binding     *bindingError
+		invalidVote *InvalidVoteError
+		invalidRows *ImportError
+	)
+	switch {
+	case errors.As(err, &binding):
+		if binding.query {
+			return http.StatusBadRequest, queryErrorToResponse(binding.err)
+		}
+		return http.StatusBadRequest, bindingErrorToResponse(binding.err)
+	case errors.As(err, &invalidVote):
+		return http.StatusBadRequest, ErrorResponse{Code: CodeInvalidVote, Message: err.Error()}
+	case errors.As(err, &invalidRows):
+		return http.StatusBadRequest, ErrorResponse{Code: CodeInvalidImport, Message: err.Error(), Details: invalidRows.Rows}
+	case errors.Is(err, ErrInvalidImport):
+		return http.StatusBadRequest, ErrorResponse{Code: CodeInvalidImport, Message: err.Error()}
+	}
+	for _, known := range errorStatuses {
+		if errors.Is(err, known.err) {
+			return known.status, ErrorResponse{Code: known.code, Message: err.Error()}
+		}
+	}
+	return http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: "internal server error"}
+}
+
+func queryErrorToResponse(err error) ErrorResponse {
+	var validation validator.ValidationErrors
+	if errors.As(err, &validation) {
+		return ErrorResponse{
+			Code:    CodeValidationFailed,
+			Message: "query is invalid",
+			Details: validationDetails(validation),
+		}
+	}
+	return ErrorResponse{Code: CodeBadRequest, Message: "query is invalid: " + err.Error()}
+}
+
+func bindingErrorToResponse(err error) ErrorResponse {
+	var (
+		validation    validator.ValidationErrors
+		unmarshalType *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &validation):
+		return ErrorResponse{
+			Code:    CodeValidationFailed,
+			Message: "request body is invalid",
+			Details: validationDetails(validation),
+		}
+	case errors.As(err, &unmarshalType):
+		return ErrorResponse{
+			Code:    CodeValidationFailed,
+			Message: "request body is invalid",
+			Details: []FieldError{{Field: unmarshalType.Field, Message: "must be " + jsonType(unmarshalType.Type)}},
+		}
+	case errors.Is(err, io.EOF):
+		return ErrorResponse{Code: CodeMalformedBody, Message: "request body is empty"}
+	default:
+		return ErrorResponse{Code: CodeMalformedBody, Message: "request body is not valid JSON: " + err.Error()}
+	}
+}
+
+func validationDetails(validation validator.ValidationErrors) []FieldError {
+	details := make([]FieldError, 0, len(validation))
+	for _, fieldErr := range validation {
+		details = append(details, FieldError{
+			Field:   fieldPath(fieldErr.Namespace()),
+			Message: validationMessage(fieldErr),
+		})
+	}
+	return details
+}
+
+// fieldPath drops the name of the request type from a validator namespace, e.g. "VoteRequest.Vote" becomes "Vote".
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(err.Param(), " ", ", ")
+	case "min":
+		return "must be at least " + err.Param()
+	case "max":
+		return "must be at most " + err.Param()
+	case "url":
+		return "must be a URL"
+	default:
+		return fmt.Sprintf("failed %q validation", err.Tag())
+	}
+}
+
+// jsonType describes a Go type the way it looks in JSON.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+var jsonFieldNamesOnce sync.Once
+
+// useJSONFieldNames makes validation errors name fields as they are named in JSON, or in the query string for fields
+// that are read from there.
+func useJSONFieldNames() {
+	jsonFieldNamesOnce.Do(func() {
+		validate, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name, _, _ = strings.Cut(field.Tag.Get("form"), ",")
+			}
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	})
+}
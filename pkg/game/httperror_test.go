@@ -0,0 +1,192 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+)
+
+func TestErrorResponses(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	voter := createUser(t)
+	join(t, voter, gameID)
+
+	tests := []struct {
+		name     string
+		token    string
+		method   string
+		path     string
+		body     string
+		status   int
+		expected game.ErrorResponse
+	}{
+		{
+			name:     "game not found",
+			method:   http.MethodGet,
+			path:     "/api/games/100",
+			status:   http.StatusNotFound,
+			expected: game.ErrorResponse{Code: game.CodeGameNotFound, Message: "game 100 not found"},
+		},
+		{
+			name:     "bad game ID",
+			token:    voter.Token,
+			method:   http.MethodPost,
+			path:     "/api/games/abc/vote",
+			body:     `{"Vote":"1"}`,
+			status:   http.StatusBadRequest,
+			expected: game.ErrorResponse{Code: game.CodeBadRequest, Message: game.ErrBadGameID.Error()},
+		},
+		{
+			name:   "missing field",
+			token:  creator.Token,
+			method: http.MethodPost,
+			path:   "/api/games",
+			body:   `{}`,
+			status: http.StatusBadRequest,
+			expected: game.ErrorResponse{
+				Code:    game.CodeValidationFailed,
+				Message: "request body is invalid",
+				Details: []game.FieldError{{Field: "gameName", Message: "is required"}},
+			},
+		},
+		{
+			name:   "wrong type",
+			token:  creator.Token,
+			method: http.MethodPost,
+			path:   "/api/games",
+			body:   `{"gameName":1}`,
+			status: http.StatusBadRequest,
+			expected: game.ErrorResponse{
+				Code:    game.CodeValidationFailed,
+				Message: "request body is invalid",
+				Details: []game.FieldError{{Field: "gameName", Message: "must be a string"}},
+			},
+		},
+		{
+			name:     "empty body",
+			token:    voter.Token,
+			method:   http.MethodPost,
+			path:     fmt.Sprintf("/api/games/%d/vote", gameID),
+			status:   http.StatusBadRequest,
+			expected: game.ErrorResponse{Code: game.CodeMalformedBody, Message: "request body is empty"},
+		},
+		{
+			name:     "invalid vote",
+			token:    voter.Token,
+			method:   http.MethodPost,
+			path:     fmt.Sprintf("/api/games/%d/vote", gameID),
+			body:     `{"Vote":"7"}`,
+			status:   http.StatusBadRequest,
+			expected: game.ErrorResponse{Code: game.CodeInvalidVote},
+		},
+		{
+			name:     "not authenticated",
+			method:   http.MethodPost,
+			path:     "/api/games",
+			body:     `{"gameName":"sprint"}`,
+			status:   http.StatusUnauthorized,
+			expected: game.ErrorResponse{Code: game.CodeUnauthorized, Message: game.ErrInvalidToken.Error()},
+		},
+		{
+			name:     "not facilitator",
+			token:    voter.Token,
+			method:   http.MethodPost,
+			path:     fmt.Sprintf("/api/games/%d/reveal", gameID),
+			status:   http.StatusForbidden,
+			expected: game.ErrorResponse{Code: game.CodeForbidden},
+		},
+		{
+			name:     "player not found",
+			token:    creator.Token,
+			method:   http.MethodDelete,
+			path:     fmt.Sprintf("/api/games/%d/players/100", gameID),
+			status:   http.StatusNotFound,
+			expected: game.ErrorResponse{Code: game.CodePlayerNotFound, Message: "player 100 not found"},
+		},
+		{
+			name:     "not in game",
+			token:    createUser(t).Token,
+			method:   http.MethodPost,
+			path:     fmt.Sprintf("/api/games/%d/vote", gameID),
+			body:     `{"Vote":"1"}`,
+			status:   http.StatusNotFound,
+			expected: game.ErrorResponse{Code: game.CodeNotInGame},
+		},
+		{
+			name:   "invalid query",
+			method: http.MethodGet,
+			path:   "/api/games?sort=players&limit=500",
+			status: http.StatusBadRequest,
+			expected: game.ErrorResponse{
+				Code:    game.CodeValidationFailed,
+				Message: "query is invalid",
+				Details: []game.FieldError{
+					{Field: "sort", Message: "must be one of: name, -name, created, -created, activity, -activity"},
+					{Field: "limit", Message: "must be at most 200"},
+				},
+			},
+		},
+		{
+			name:     "malformed query",
+			method:   http.MethodGet,
+			path:     "/api/games?limit=many",
+			status:   http.StatusBadRequest,
+			expected: game.ErrorResponse{Code: game.CodeBadRequest},
+		},
+		{
+			name:     "invalid cursor",
+			method:   http.MethodGet,
+			path:     "/api/games?cursor=abc",
+			status:   http.StatusBadRequest,
+			expected: game.ErrorResponse{Code: game.CodeInvalidCursor, Message: game.ErrInvalidCursor.Error()},
+		},
+		{
+			name:     "unknown endpoint",
+			method:   http.MethodGet,
+			path:     "/api/tables",
+			status:   http.StatusNotFound,
+			expected: game.ErrorResponse{Code: game.CodeNotFound, Message: "no such endpoint"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := doRaw(t, test.token, test.method, test.path, test.body)
+			require.Equal(t, test.status, resp.StatusCode)
+			var errResp game.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+			require.NotEmpty(t, errResp.RequestID)
+			require.Equal(t, resp.Header.Get("X-Request-ID"), errResp.RequestID)
+			require.Equal(t, test.expected.Code, errResp.Code)
+			require.NotEmpty(t, errResp.Message)
+			if test.expected.Message != "" {
+				require.Equal(t, test.expected.Message, errResp.Message)
+			}
+			require.Equal(t, test.expected.Details, errResp.Details)
+		})
+	}
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+
+	req, err := http.NewRequest(http.MethodGet, fullPath("/api/games/100"), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "trace-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "trace-42", resp.Header.Get("X-Request-ID"))
+	var errResp game.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, "trace-42", errResp.RequestID)
+}
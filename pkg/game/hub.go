@@ -0,0 +1,218 @@
+package game
+
+import (
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// EventType identifies what happened in a game.
+type EventType string
+
+const (
+	EventGameSnapshot  EventType = "game_snapshot" // full game state, sent on connect
+	EventGameCreated   EventType = "game_created"  // payload is the game, only observers of the hub get it
+	EventPlayerJoined  EventType = "player_joined"
+	EventPlayerLeft    EventType = "player_left"  // payload is a PlayerLeft
+	EventGameDeleted   EventType = "game_deleted" // the last event of a game, subscriptions end after it
+	EventGameExpired   EventType = "game_expired" // payload is a GameExpired, subscriptions end after it
+	EventVoteCast      EventType = "vote_cast"
+	EventRoundRevealed EventType = "round_revealed" // payload is the game with votes visible
+	EventRoundStarted  EventType = "round_started"
+	EventRolesChanged  EventType = "roles_changed"
+	EventGameRenamed   EventType = "game_renamed"
+
+	EventStoryAdded        EventType = "story_added"
+	EventStoryRemoved      EventType = "story_removed"
+	EventStoriesReordered  EventType = "stories_reordered" // payload is the new order of story IDs
+	EventStoryStarted      EventType = "story_started"     // current story changed and a new round started
+	EventStoriesImported   EventType = "stories_imported"  // payload is a StoriesImported
+	EventEstimateFinalized EventType = "estimate_finalized"
+
+	EventTimerStarted   EventType = "timer_started" // payload of timer events is a TimerEvent
+	EventTimerTick      EventType = "timer_tick"    // sent every second while the timer runs
+	EventTimerExpired   EventType = "timer_expired" // followed by EventRoundRevealed or EventVotingClosed, see TimerAction
+	EventTimerCancelled EventType = "timer_cancelled"
+	EventVotingClosed   EventType = "voting_closed"
+
+	EventSettingsChanged EventType = "settings_changed" // payload is a SettingsChanged
+	EventPresenceChanged EventType = "presence_changed" // payload is a PresenceChanged
+)
+
+// subscriptionBuffer is how many events can wait for a slow subscriber before new ones are dropped.
+const subscriptionBuffer = 32
+
+// Event is a single change in a game's state. Payload depends on Type.
+type Event struct {
+	Type    EventType       `json:"type"`
+	GameID  GameID          `json:"gameId"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// VoteCast is a payload of EventVoteCast. The vote itself stays hidden until the round is revealed.
+type VoteCast struct {
+	PlayerID PlayerID `json:"playerId"`
+}
+
+// PlayerLeft is a payload of EventPlayerLeft. Removed is set if the facilitator removed the player from the game.
+type PlayerLeft struct {
+	PlayerID PlayerID `json:"playerId"`
+	Removed  bool     `json:"removed"`
+}
+
+// GameExpired is a payload of EventGameExpired. The game was either archived or deleted.
+type GameExpired struct {
+	Archived     bool      `json:"archived"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+// StoryRef is a payload of events that refer to a story.
+type StoryRef struct {
+	StoryID StoryID `json:"storyId"`
+}
+
+// NewEvent creates an event with payload encoded as JSON. Payload is encoded once and then shared by all subscribers.
+func NewEvent(eventType EventType, gameID GameID, payload any) Event {
+	event := Event{
+		Type:   eventType,
+		GameID: gameID,
+		Time:   time.Now(),
+	}
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"game_id": gameID, "event": eventType}).WithError(err).
+				Error("Failed to encode event payload")
+		}
+		event.Payload = encoded
+	}
+	return event
+}
+
+// Subscription receives events of a single game until it is unsubscribed or the hub is closed.
+type Subscription struct {
+	gameID GameID
+	events chan Event
+}
+
+// Events returns a channel with game events. The channel is closed when subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Hub distributes game events to subscribers of that game.
+type Hub struct {
+	subscribers map[GameID]map[*Subscription]struct{}
+	observers   []func(Event) // get events of all games, see Observe
+	closed      bool
+	lock        sync.RWMutex // protects subscribers, observers and closed
+	metrics     *Metrics     // counts dropped events, nil if not instrumented
+	log         logrus.FieldLogger
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[GameID]map[*Subscription]struct{}),
+		lock:        sync.RWMutex{},
+		log:         logrus.StandardLogger(),
+	}
+}
+
+// Subscribe starts receiving events of a game. If the hub is already closed returned subscription is closed too.
+func (h *Hub) Subscribe(gameID GameID) *Subscription {
+	sub := &Subscription{
+		gameID: gameID,
+		events: make(chan Event, subscriptionBuffer),
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		close(sub.events)
+		return sub
+	}
+	gameSubs, ok := h.subscribers[gameID]
+	if !ok {
+		gameSubs = make(map[*Subscription]struct{})
+		h.subscribers[gameID] = gameSubs
+	}
+	gameSubs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivering events to sub and closes its channel. It is safe to call it more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	gameSubs, ok := h.subscribers[sub.gameID]
+	if !ok {
+		return
+	}
+	if _, ok = gameSubs[sub]; !ok {
+		return
+	}
+	delete(gameSubs, sub)
+	if len(gameSubs) == 0 {
+		delete(h.subscribers, sub.gameID)
+	}
+	close(sub.events)
+}
+
+// Observe calls observe with every published event of every game, including EventGameCreated. Unlike subscribers,
+// observers never miss an event, as they are called by Publish itself; so they must return quickly and never block.
+// Observers are not called after the hub is closed.
+func (h *Hub) Observe(observe func(Event)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.observers = append(h.observers, observe)
+}
+
+// Publish sends event to every subscriber of its game and to observers. It never blocks: if a subscriber can't keep
+// up, the event is dropped for that subscriber.
+func (h *Hub) Publish(event Event) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if !h.closed {
+		for _, observe := range h.observers {
+			observe(event)
+		}
+	}
+	for sub := range h.subscribers[event.GameID] {
+		select {
+		case sub.events <- event:
+		default:
+			h.metrics.wsMessageDropped()
+			h.log.WithFields(logrus.Fields{"game_id": event.GameID, "event": event.Type}).
+				Warn("Dropped event for a slow subscriber")
+		}
+	}
+}
+
+// CloseGame ends all subscriptions to a game, e.g. because it was deleted. Events published before are still
+// delivered.
+func (h *Hub) CloseGame(gameID GameID) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for sub := range h.subscribers[gameID] {
+		close(sub.events)
+	}
+	delete(h.subscribers, gameID)
+}
+
+// Close ends all subscriptions. Subscriptions made after Close are closed immediately.
+func (h *Hub) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, gameSubs := range h.subscribers {
+		for sub := range gameSubs {
+			close(sub.events)
+		}
+	}
+	h.subscribers = nil
+}
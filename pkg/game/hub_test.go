@@ -0,0 +1,92 @@
+package game_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"testing"
+)
+
+func TestHubPublishToGameSubscribers(t *testing.T) {
+	hub := game.NewHub()
+	defer hub.Close()
+	sub1 := hub.Subscribe(1)
+	sub2 := hub.Subscribe(1)
+	otherGame := hub.Subscribe(2)
+
+	event := game.NewEvent(game.EventPlayerJoined, 1, game.PlayerResponse{ID: 1, Name: "bobby"})
+	hub.Publish(event)
+
+	require.Equal(t, event, <-sub1.Events())
+	require.Equal(t, event, <-sub2.Events())
+	require.Empty(t, otherGame.Events())
+}
+
+func TestHubObserve(t *testing.T) {
+	hub := game.NewHub()
+	var observed []game.Event
+	hub.Observe(func(event game.Event) { observed = append(observed, event) })
+	created := game.NewEvent(game.EventGameCreated, 1, nil)
+	hub.Publish(created)
+	sub := hub.Subscribe(2)
+	for i := 0; i < 100; i++ { // more than a subscriber can take without reading
+		hub.Publish(game.NewEvent(game.EventTimerTick, 2, nil))
+	}
+	hub.CloseGame(1)
+	hub.Close()
+	hub.Publish(game.NewEvent(game.EventVoteCast, 2, nil))
+
+	require.Len(t, observed, 101)
+	require.Equal(t, created, observed[0])
+	require.Len(t, sub.Events(), 32)
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub := game.NewHub()
+	defer hub.Close()
+	sub := hub.Subscribe(1)
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(sub) // second time is a no-op
+
+	hub.Publish(game.NewEvent(game.EventVoteCast, 1, nil))
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+}
+
+func TestHubSlowSubscriberDoesNotBlock(t *testing.T) {
+	hub := game.NewHub()
+	defer hub.Close()
+	sub := hub.Subscribe(1)
+	for i := 0; i < 1000; i++ {
+		hub.Publish(game.NewEvent(game.EventVoteCast, 1, nil))
+	}
+	require.NotEmpty(t, sub.Events())
+}
+
+func TestHubClose(t *testing.T) {
+	hub := game.NewHub()
+	sub := hub.Subscribe(1)
+	hub.Close()
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+
+	afterClose := hub.Subscribe(1)
+	_, ok = <-afterClose.Events()
+	require.False(t, ok)
+}
+
+func TestHubCloseGame(t *testing.T) {
+	hub := game.NewHub()
+	defer hub.Close()
+	sub := hub.Subscribe(1)
+	otherGame := hub.Subscribe(2)
+	event := game.NewEvent(game.EventGameDeleted, 1, nil)
+	hub.Publish(event)
+	hub.CloseGame(1)
+
+	require.Equal(t, event, <-sub.Events()) // published events are still delivered
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+	hub.Unsubscribe(sub) // no-op after the game is closed
+	hub.Publish(game.NewEvent(game.EventVoteCast, 2, nil))
+	require.Len(t, otherGame.Events(), 1)
+}
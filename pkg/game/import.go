@@ -0,0 +1,224 @@
+package game
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+)
+
+var ErrInvalidImport = errors.New("stories can't be imported")
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+var ErrImportTooLarge = errors.New("the file of stories is too large")
+
+// ImportMode tells what happens to the backlog of a game when stories are imported.
+type ImportMode string
+
+const (
+	ImportAppend  ImportMode = "append"  // imported stories are added to the end of the backlog
+	ImportReplace ImportMode = "replace" // the backlog, with rounds of its stories, is replaced by imported stories
+)
+
+// MaxImportedStories is how many stories can be imported at once.
+const MaxImportedStories = 500
+
+// StoryColumns are the names of CSV columns stories are read from. Names are matched in any case, other columns are
+// ignored. Only the title column is required.
+type StoryColumns struct {
+	Key         string
+	Title       string
+	Description string
+	Link        string
+}
+
+// DefaultStoryColumns are columns named after fields of a story.
+var DefaultStoryColumns = StoryColumns{Key: "key", Title: "title", Description: "description", Link: "link"}
+
+// columns returns DefaultStoryColumns with the names set in req.
+func (req ImportStoriesRequest) columns() StoryColumns {
+	columns := DefaultStoryColumns
+	if req.KeyColumn != "" {
+		columns.Key = req.KeyColumn
+	}
+	if req.TitleColumn != "" {
+		columns.Title = req.TitleColumn
+	}
+	if req.DescriptionColumn != "" {
+		columns.Description = req.DescriptionColumn
+	}
+	if req.LinkColumn != "" {
+		columns.Link = req.LinkColumn
+	}
+	return columns
+}
+
+// StoriesImported is a payload of EventStoriesImported.
+type StoriesImported struct {
+	Mode    ImportMode `json:"mode"`
+	Stories []Story    `json:"stories"`
+}
+
+// ImportError tells which rows of an import are invalid. Nothing is imported if any row is.
+type ImportError struct {
+	Rows []FieldError // fields are named like "rows[3].title", rows are numbered from 1
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%s: %d invalid rows", ErrInvalidImport, len(e.Rows))
+}
+
+func (e *ImportError) Unwrap() error { return ErrInvalidImport }
+
+// ImportStories adds stories to the game's backlog or replaces the backlog with them. Only the facilitator can import
+// stories. Stories must be valid already, see ParseStoriesCSV and ParseStoriesJSON. If no story is being estimated
+// afterwards, the first imported one becomes current.
+func (d *Dealer) ImportStories(gameID GameID, playerID PlayerID, stories []StoryRequest, mode ImportMode) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	if mode == "" {
+		mode = ImportAppend
+	}
+	restarted := false // the current round was reset
+	if mode == ImportReplace {
+		replaced := make(map[StoryID]bool, len(game.Stories))
+		for _, story := range game.Stories {
+			replaced[story.ID] = true
+		}
+		rounds := game.History[:0]
+		for _, round := range game.History {
+			if !replaced[round.StoryID] {
+				rounds = append(rounds, round)
+			}
+		}
+		game.History = rounds
+		game.Stories = nil
+		if game.CurrentStoryID != 0 {
+			d.startStory(game, 0)
+			restarted = true
+		}
+	}
+	imported := make([]Story, 0, len(stories))
+	for _, req := range stories {
+		imported = append(imported, appendStory(game, req))
+	}
+	started := game.CurrentStoryID == 0 && len(imported) > 0
+	if started {
+		d.startStory(game, imported[0].ID)
+	}
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	if started || restarted {
+		d.cancelTimer(game)
+	}
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "mode": mode, "stories": len(imported)}).Info("Stories imported")
+	d.hub.Publish(NewEvent(EventStoriesImported, gameID, StoriesImported{Mode: mode, Stories: imported}))
+	if started {
+		d.hub.Publish(NewEvent(EventStoryStarted, gameID, StoryRef{StoryID: game.CurrentStoryID}))
+	}
+	return gameToResponse(game), nil
+}
+
+// ParseStoriesCSV reads stories from CSV with a header row. Rows are validated like StoryRequest of a single story,
+// an ImportError lists invalid ones.
+func ParseStoriesCSV(r io.Reader, columns StoryColumns) ([]StoryRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // missing trailing cells are empty
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // spreadsheets like to start UTF-8 files with a BOM
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	if _, ok := index[strings.ToLower(columns.Title)]; !ok {
+		return nil, fmt.Errorf("%w: there is no %q column", ErrInvalidImport, columns.Title)
+	}
+
+	var stories []StoryRequest
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+		}
+		if len(stories) == MaxImportedStories {
+			return nil, fmt.Errorf("%w: more than %d stories", ErrInvalidImport, MaxImportedStories)
+		}
+		cell := func(column string) string {
+			i, ok := index[strings.ToLower(column)]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		stories = append(stories, StoryRequest{
+			Key:         cell(columns.Key),
+			Title:       cell(columns.Title),
+			Description: cell(columns.Description),
+			Link:        cell(columns.Link),
+		})
+	}
+	return stories, validateStories(stories)
+}
+
+// ParseStoriesJSON reads stories from a JSON array of StoryRequest. Invalid stories are reported like by
+// ParseStoriesCSV.
+func ParseStoriesJSON(r io.Reader) ([]StoryRequest, error) {
+	var stories []StoryRequest
+	if err := json.NewDecoder(r).Decode(&stories); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: the body is empty", ErrInvalidImport)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err)
+	}
+	if len(stories) > MaxImportedStories {
+		return nil, fmt.Errorf("%w: more than %d stories", ErrInvalidImport, MaxImportedStories)
+	}
+	return stories, validateStories(stories)
+}
+
+func validateStories(stories []StoryRequest) error {
+	if len(stories) == 0 {
+		return fmt.Errorf("%w: there are no stories", ErrInvalidImport)
+	}
+	var rows []FieldError
+	for i := range stories {
+		err := binding.Validator.ValidateStruct(&stories[i])
+		var validation validator.ValidationErrors
+		if err != nil && !errors.As(err, &validation) {
+			return err
+		}
+		for _, detail := range validationDetails(validation) {
+			detail.Field = fmt.Sprintf("rows[%d].%s", i+1, detail.Field)
+			rows = append(rows, detail)
+		}
+	}
+	if len(rows) > 0 {
+		return &ImportError{Rows: rows}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package game_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestImportStories(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	conn := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+
+	// columns are matched in any case, unknown ones are ignored and cells may contain commas and line breaks
+	csvFile := "\ufeffIssue key,Summary,Description,Link,Priority\n" +
+		"PRJ-1,Login,\"Users sign in,\nwith SSO\",https://tracker.example.com/PRJ-1,high\n" +
+		"PRJ-2,  Logout  \n"
+	resp := importStories(t, creator.Token, gameID, "?keyColumn=issue+key&titleColumn=SUMMARY", "text/csv", csvFile)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	poker := decodeGame(t, resp)
+	require.Len(t, poker.Stories, 2)
+	require.Equal(t, game.Story{
+		ID:          poker.Stories[0].ID,
+		Key:         "PRJ-1",
+		Title:       "Login",
+		Description: "Users sign in,\nwith SSO",
+		Link:        "https://tracker.example.com/PRJ-1",
+	}, poker.Stories[0].Story)
+	require.Equal(t, "Logout", poker.Stories[1].Title)
+	require.Equal(t, poker.Stories[0].ID, poker.CurrentStoryID)
+	event := readEvent(t, conn)
+	require.Equal(t, game.EventStoriesImported, event.Type)
+	var imported game.StoriesImported
+	require.NoError(t, json.Unmarshal(event.Payload, &imported))
+	require.Equal(t, game.ImportAppend, imported.Mode)
+	require.Len(t, imported.Stories, 2)
+	require.Equal(t, game.EventStoryStarted, readEvent(t, conn).Type)
+
+	// appending keeps the current story
+	vote(t, creator, "5", gameID)
+	reveal(t, creator.Token, gameID)
+	resp = importStories(t, creator.Token, gameID, "", "application/json", `[{"key": "PRJ-3", "title": "Search"}]`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	poker = decodeGame(t, resp)
+	require.Len(t, poker.Stories, 3)
+	require.Equal(t, "Search", poker.Stories[2].Title)
+	require.Equal(t, poker.Stories[0].ID, poker.CurrentStoryID)
+	require.Len(t, poker.Stories[0].Rounds, 1)
+	expectEvents(t, conn, game.EventVoteCast, game.EventRoundRevealed, game.EventStoriesImported)
+
+	// replacing drops the backlog with its rounds
+	resp = importStories(t, creator.Token, gameID, "?mode=replace", "application/json", `[{"title": "Billing"}]`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	poker = decodeGame(t, resp)
+	require.Len(t, poker.Stories, 1)
+	require.Equal(t, "Billing", poker.Stories[0].Title)
+	require.Equal(t, poker.Stories[0].ID, poker.CurrentStoryID)
+	require.Equal(t, game.RoundVoting, poker.State)
+	require.Empty(t, poker.Stories[0].Rounds)
+	require.Empty(t, poker.Rounds)
+	expectEvents(t, conn, game.EventStoriesImported, game.EventStoryStarted)
+}
+
+func TestImportStoriesErrors(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+
+	tests := []struct {
+		name         string
+		token        string
+		query        string
+		contentType  string
+		body         string
+		responseCode int
+		code         game.ErrorCode
+		details      []game.FieldError
+	}{
+		{
+			name:         "not facilitator",
+			token:        voter.Token,
+			contentType:  "application/json",
+			body:         `[{"title": "Login"}]`,
+			responseCode: http.StatusForbidden,
+			code:         game.CodeForbidden,
+		},
+		{
+			name:         "invalid rows",
+			contentType:  "application/json",
+			body:         `[{"title": "Login"}, {"key": "PRJ-2"}, {"title": "Logout", "link": "not a link"}]`,
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+			details: []game.FieldError{
+				{Field: "rows[2].title", Message: "is required"},
+				{Field: "rows[3].link", Message: "must be a URL"},
+			},
+		},
+		{
+			name:         "invalid csv rows",
+			contentType:  "text/csv",
+			body:         "key,title\nPRJ-1,Login\nPRJ-2,\n",
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+			details:      []game.FieldError{{Field: "rows[2].title", Message: "is required"}},
+		},
+		{
+			name:         "no title column",
+			contentType:  "text/csv",
+			body:         "key,summary\nPRJ-1,Login\n",
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+		},
+		{
+			name:         "malformed csv",
+			contentType:  "text/csv",
+			body:         "key,title\nPRJ-1,\"Login\n",
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+		},
+		{
+			name:         "not an array",
+			contentType:  "application/json",
+			body:         `{"title": "Login"}`,
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+		},
+		{
+			name:         "no stories",
+			contentType:  "application/json",
+			body:         `[]`,
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+		},
+		{
+			name:         "too many stories",
+			contentType:  "text/csv",
+			body:         "title\n" + strings.Repeat("Login\n", game.MaxImportedStories+1),
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidImport,
+		},
+		{
+			name:         "too large",
+			contentType:  "text/csv",
+			body:         "title\n" + strings.Repeat("x", 1<<20) + "\n",
+			responseCode: http.StatusRequestEntityTooLarge,
+			code:         game.CodeImportTooLarge,
+		},
+		{
+			name:         "unknown mode",
+			query:        "?mode=merge",
+			contentType:  "application/json",
+			body:         `[{"title": "Login"}]`,
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeValidationFailed,
+			details:      []game.FieldError{{Field: "mode", Message: "must be one of: append, replace"}},
+		},
+		{
+			name:         "unsupported type",
+			contentType:  "application/vnd.ms-excel",
+			body:         "Login",
+			responseCode: http.StatusUnsupportedMediaType,
+			code:         game.CodeUnsupportedType,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := test.token
+			if token == "" {
+				token = creator.Token
+			}
+			resp := importStories(t, token, gameID, test.query, test.contentType, test.body)
+			require.Equal(t, test.responseCode, resp.StatusCode)
+			var errResp game.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+			require.Equal(t, test.code, errResp.Code)
+			require.Equal(t, test.details, errResp.Details)
+		})
+	}
+	require.Empty(t, getGame(t, gameID).Stories)
+}
+
+func importStories(t *testing.T, token string, gameID game.GameID, query, contentType, body string) *http.Response {
+	path := fmt.Sprintf("/api/games/%d/stories/import%s", gameID, query)
+	req, err := http.NewRequest(http.MethodPost, fullPath(path), bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeGame(t *testing.T, resp *http.Response) game.GameResponse {
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	return poker
+}
@@ -0,0 +1,38 @@
+package game
+
+import (
+	"errors"
+	"gpoker/gen"
+)
+
+// maxJoinCodeAttempts limits how many codes are generated for a game before giving up. There are about 887 million
+// codes, so even one collision is unlikely.
+const maxJoinCodeAttempts = 10
+
+var errNoJoinCode = errors.New("failed to generate a unique join code")
+
+// newJoinCode generates a join code that no game of the Dealer has. The Dealer must be locked for writing.
+func (d *Dealer) newJoinCode() (string, error) {
+	for i := 0; i < maxJoinCodeAttempts; i++ {
+		code, err := gen.JoinCode()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := d.codes[code]; !taken {
+			return code, nil
+		}
+	}
+	return "", errNoJoinCode
+}
+
+// GameByCode finds the game with the join code. The code is accepted as people type it, e.g. in lower case or
+// without the dash.
+func (d *Dealer) GameByCode(code string) (GameID, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	gameID, ok := d.codes[gen.NormalizeJoinCode(code)]
+	if !ok {
+		return 0, ErrJoinCodeNotFound
+	}
+	return gameID, nil
+}
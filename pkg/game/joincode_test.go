@@ -0,0 +1,163 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJoinByCode(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	poker := getGameAs(t, creator.Token, gameID)
+	require.Regexp(t, `^[2-9A-HJKMNP-Z]{3}-[2-9A-HJKMNP-Z]{3}$`, poker.Code)
+	require.NotEqual(t, poker.Code, getGameAs(t, creator.Token, createDefaultGame(t, creator)).Code)
+
+	typed := strings.ToLower(strings.ReplaceAll(poker.Code, "-", "")) // as a person would type it
+	resp, err := http.Get(fullPath("/api/join/" + typed))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var found game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&found))
+	require.Equal(t, gameID, found.ID)
+
+	observer := createUser(t)
+	resp = doJSON(t, observer.Token, http.MethodPut, "/api/join/"+poker.Code, game.JoinPokerRequest{Role: game.RoleObserver})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var joined game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&joined))
+	require.Equal(t, gameID, joined.ID)
+	require.Equal(t, game.RoleObserver, findPlayer(t, joined, observer.ID).Role)
+
+	voter := createUser(t)
+	resp = doJSON(t, voter.Token, http.MethodPut, "/api/join/"+typed, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	vote(t, voter, "5", gameID)
+}
+
+func TestJoinCodeIsShownOnlyToPlayers(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	stranger := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+
+	code := getGameAs(t, creator.Token, gameID).Code
+	require.NotEmpty(t, code)
+	require.Equal(t, code, getGameAs(t, voter.Token, gameID).Code)
+	require.Empty(t, getGame(t, gameID).Code)
+	require.Empty(t, getGameAs(t, stranger.Token, gameID).Code)
+	resp := doJSON(t, "invalid", http.MethodGet, fmt.Sprintf("/api/games/%d", gameID), nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	tests := []struct {
+		name string
+		conn *websocket.Conn
+		code string
+	}{
+		{"anonymous", dialGame(t, gameID), ""},
+		{"not a player", dialGameAs(t, stranger.Token, gameID, http.StatusSwitchingProtocols), ""},
+		{"player", dialGameAs(t, voter.Token, gameID, http.StatusSwitchingProtocols), code},
+	}
+	for _, test := range tests {
+		snapshot := readEvent(t, test.conn)
+		require.Equal(t, game.EventGameSnapshot, snapshot.Type, test.name)
+		var poker game.GameResponse
+		require.NoError(t, json.Unmarshal(snapshot.Payload, &poker))
+		require.Equal(t, test.code, poker.Code, test.name)
+	}
+
+	vote(t, voter, "5", gameID)
+	revealed := reveal(t, creator.Token, gameID)
+	require.Equal(t, code, revealed.Code)
+	for _, test := range tests {
+		event := readEvent(t, test.conn)
+		for event.Type != game.EventRoundRevealed {
+			event = readEvent(t, test.conn)
+		}
+		var poker game.GameResponse
+		require.NoError(t, json.Unmarshal(event.Payload, &poker))
+		require.Empty(t, poker.Code, test.name)
+	}
+}
+
+func TestJoinByUnknownCode(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+
+	resp, err := http.Get(fullPath("/api/join/AAA-AAA"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var errResp game.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, game.CodeJoinCodeNotFound, errResp.Code)
+
+	resp = doJSON(t, createUser(t).Token, http.MethodPut, "/api/join/AAA-AAA", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestJoinCodeIsGivenToOldGames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	require.NoError(t, store.SaveGame(&game.Poker{ // as saved before join codes
+		ID:      1,
+		Name:    "old",
+		Players: map[game.PlayerID]game.Player{creator.ID: creator},
+		Roles:   map[game.PlayerID]game.Role{creator.ID: game.RoleFacilitator},
+		Votes:   map[game.PlayerID]game.Vote{},
+		State:   game.RoundVoting,
+	}))
+	require.NoError(t, store.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	poker, ok := dealer.GetGame(1)
+	require.True(t, ok)
+	require.NotEmpty(t, poker.Code)
+	gameID, err := dealer.GameByCode(poker.Code)
+	require.NoError(t, err)
+	require.Equal(t, game.GameID(1), gameID)
+	require.NoError(t, store.Close())
+
+	// the code is saved, so it doesn't change with every restart
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	dealer, err = game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	reloaded, ok := dealer.GetGame(1)
+	require.True(t, ok)
+	require.Equal(t, poker.Code, reloaded.Code)
+}
+
+func TestDeletedGameCodeIsFreed(t *testing.T) {
+	dealer := game.NewDealer()
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	poker, err := dealer.CreateGame("sprint", creator, deck)
+	require.NoError(t, err)
+	require.NoError(t, dealer.DeleteGame(poker.ID, creator.ID))
+	_, err = dealer.GameByCode(poker.Code)
+	require.ErrorIs(t, err, game.ErrJoinCodeNotFound)
+}
@@ -0,0 +1,98 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+var ErrUnknownLogFormat = errors.New("unknown log format")
+
+const (
+	LogFormatLogfmt = "logfmt" // key=value pairs, one record per line
+	LogFormatJSON   = "json"   // a JSON object per line
+
+	loggerKey = "gpoker.logger" // gin.Context key of the request's *logrus.Entry
+)
+
+// NewLogger creates a logger writing records of at least level in format to out.
+func NewLogger(level, format string, out io.Writer) (*logrus.Logger, error) {
+	logger := logrus.New()
+	logger.SetOutput(out)
+	parsedLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(parsedLevel)
+	switch format {
+	case LogFormatLogfmt:
+		logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+	case LogFormatJSON:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownLogFormat, format)
+	}
+	return logger, nil
+}
+
+func (cfg Config) logger() (*logrus.Logger, error) {
+	out := cfg.LogOutput
+	if out == nil {
+		out = os.Stderr
+	}
+	return NewLogger(cfg.LogLevel, cfg.LogFormat, out)
+}
+
+// logRequests is a middleware that gives every request a logger with its ID and the client's address, and logs the
+// request once it's handled. It must go after assignRequestID.
+func logRequests(log *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		entry := log.WithFields(logrus.Fields{
+			"request_id":  requestID(c),
+			"remote_addr": c.ClientIP(),
+		})
+		c.Set(loggerKey, entry)
+		c.Next()
+
+		fields := logrus.Fields{
+			"method":   c.Request.Method,
+			"path":     c.Request.URL.Path,
+			"route":    c.FullPath(),
+			"status":   c.Writer.Status(),
+			"duration": time.Since(start).String(),
+		}
+		if gameID := c.Param("gameId"); gameID != "" {
+			fields["game_id"] = gameID
+		}
+		entry = logger(c).WithFields(fields)
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request failed")
+		case c.FullPath() == "/health":
+			entry.Debug("Request handled") // polled all the time, not worth logging by default
+		default:
+			entry.Info("Request handled")
+		}
+	}
+}
+
+// logger returns the logger of the request set by logRequests. Further fields are added with withLogFields.
+func logger(c *gin.Context) *logrus.Entry {
+	if entry, ok := c.Get(loggerKey); ok {
+		return entry.(*logrus.Entry)
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// withLogFields adds fields to the logger of the request, so every later record of the request has them.
+func withLogFields(c *gin.Context, fields logrus.Fields) *logrus.Entry {
+	entry := logger(c).WithFields(fields)
+	c.Set(loggerKey, entry)
+	return entry
+}
@@ -0,0 +1,106 @@
+package game_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestLogs(t *testing.T) {
+	var logs lockedBuffer
+	cfg := game.DefaultConfig()
+	cfg.LogFormat = game.LogFormatJSON
+	cfg.LogOutput = &logs
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+
+	req, err := http.NewRequest(http.MethodPost, fullPath(fmt.Sprintf("/api/games/%d/vote", gameID)),
+		strings.NewReader(`{"Vote":"5"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+creator.Token)
+	req.Header.Set("X-Request-ID", "vote-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	record := waitForLog(t, &logs, "request_id", "vote-1")
+	require.Equal(t, "info", record["level"])
+	require.Equal(t, "Request handled", record["msg"])
+	require.Equal(t, "/api/games/:gameId/vote", record["route"])
+	require.Equal(t, float64(http.StatusOK), record["status"])
+	require.Equal(t, fmt.Sprint(gameID), record["game_id"])
+	require.Equal(t, float64(creator.ID), record["player_id"])
+	require.NotEmpty(t, record["remote_addr"])
+
+	created := waitForLog(t, &logs, "msg", "Game created")
+	require.Equal(t, float64(gameID), created["game_id"])
+	require.Equal(t, float64(creator.ID), created["player_id"])
+	require.NotContains(t, logs.String(), `"route":"/health"`) // debug level
+}
+
+func TestNewLoggerErrors(t *testing.T) {
+	_, err := game.NewLogger("loud", game.LogFormatJSON, &bytes.Buffer{})
+	require.Error(t, err)
+	_, err = game.NewLogger("info", "xml", &bytes.Buffer{})
+	require.ErrorIs(t, err, game.ErrUnknownLogFormat)
+
+	cfg := game.DefaultConfig()
+	cfg.LogFormat = "xml"
+	_, err = game.NewServer(cfg)
+	require.ErrorIs(t, err, game.ErrUnknownLogFormat)
+}
+
+func TestLogfmt(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := game.NewLogger("debug", game.LogFormatLogfmt, &out)
+	require.NoError(t, err)
+	logger.WithField("game_id", 1).Debug("Game created")
+	require.Contains(t, out.String(), `level=debug msg="Game created" game_id=1`)
+}
+
+// waitForLog returns the first JSON log record with field equal to value.
+func waitForLog(t *testing.T, logs *lockedBuffer, field, value string) map[string]any {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, line := range strings.Split(logs.String(), "\n") {
+			var record map[string]any
+			if json.Unmarshal([]byte(line), &record) == nil && record[field] == value {
+				return record
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no log record with %s=%s in %s", field, value, logs.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe to read while the server writes logs.
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,162 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaveGame(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	player := createUser(t)
+	join(t, player, gameID)
+	vote(t, player, "5", gameID)
+	path := fmt.Sprintf("/api/games/%d/join", gameID)
+
+	resp := doJSON(t, player.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	poker := reveal(t, creator.Token, gameID)
+	require.Equal(t, []game.PlayerResponse{{ID: creator.ID, Name: creator.Name, Role: game.RoleFacilitator}}, poker.Players)
+	require.Zero(t, poker.Stats.Votes)
+	voteExpect(t, player.Token, "5", gameID, http.StatusNotFound)
+
+	resp = doJSON(t, player.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = doJSON(t, player.Token, http.MethodDelete, "/api/games/100/join", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestFacilitatorLeaves(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	observer := createUser(t)
+	joinAs(t, observer.Token, gameID, game.RoleObserver, http.StatusOK)
+	voter := createUser(t)
+	join(t, voter, gameID)
+
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/join", gameID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	poker := getGame(t, gameID)
+	require.Equal(t, game.RoleFacilitator, findPlayer(t, poker, voter.ID).Role) // voters are preferred
+	require.Equal(t, game.RoleObserver, findPlayer(t, poker, observer.ID).Role)
+
+	resp = doJSON(t, voter.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/join", gameID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, game.RoleFacilitator, findPlayer(t, getGame(t, gameID), observer.ID).Role)
+}
+
+func TestRemovePlayer(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	player := createUser(t)
+	join(t, player, gameID)
+	vote(t, player, "5", gameID)
+	conn := dialGame(t, gameID)
+	readEvent(t, conn) // snapshot
+	path := fmt.Sprintf("/api/games/%d/players/%d", gameID, creator.ID)
+
+	resp := doJSON(t, player.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	path = fmt.Sprintf("/api/games/%d/players/%d", gameID, player.ID)
+	resp = doJSON(t, creator.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	left := readEvent(t, conn)
+	require.Equal(t, game.EventPlayerLeft, left.Type)
+	var payload game.PlayerLeft
+	require.NoError(t, json.Unmarshal(left.Payload, &payload))
+	require.Equal(t, game.PlayerLeft{PlayerID: player.ID, Removed: true}, payload)
+	poker := getGame(t, gameID)
+	require.Len(t, poker.Players, 1)
+	require.False(t, poker.Players[0].Voted)
+
+	resp = doJSON(t, creator.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/players/abc", gameID), nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDeleteGame(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	other := createDefaultGame(t, creator)
+	player := createUser(t)
+	join(t, player, gameID)
+	conn := dialGame(t, gameID)
+	readEvent(t, conn) // snapshot
+	path := fmt.Sprintf("/api/games/%d", gameID)
+
+	resp := doJSON(t, player.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, creator.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, game.EventGameDeleted, readEvent(t, conn).Type)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %s", err)
+
+	resp, err = http.Get(fullPath(path))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	games := listGames(t)
+	require.Len(t, games, 1)
+	require.Equal(t, other, games[0].ID)
+
+	resp = doJSON(t, creator.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDeletedGameIsNotRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	deck, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	creator := game.Player{ID: 1, Name: "bobby"}
+	poker, err := dealer.CreateGame("first", creator, deck)
+	require.NoError(t, err)
+	require.NoError(t, dealer.DeleteGame(poker.ID, creator.ID))
+	require.NoError(t, store.Close())
+
+	store, err = game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	dealer, err = game.NewDealerWithStore(store)
+	require.NoError(t, err)
+	games, err := dealer.ListGames(game.ListGamesRequest{Status: game.GameStatusAll})
+	require.NoError(t, err)
+	require.Empty(t, games.Games)
+	next, err := dealer.CreateGame("second", creator, deck)
+	require.NoError(t, err)
+	require.Equal(t, poker.ID+1, next.ID) // IDs of deleted games are not reused
+}
+
+func dialGame(t *testing.T, gameID game.GameID) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:8080/ws/games/%d", gameID), http.Header{})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
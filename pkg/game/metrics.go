@@ -0,0 +1,214 @@
+package game
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const metricsNamespace = "gpoker"
+
+// Metrics are Prometheus metrics of a Server. Every Server has its own registry, so several servers can run in one
+// process. All methods do nothing on a nil *Metrics, so a Dealer or a PlayerRegistry works without them.
+type Metrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec   // by route, method and status
+	requestDuration   *prometheus.HistogramVec // by route and method
+	games             prometheus.Gauge
+	players           prometheus.Gauge
+	votes             prometheus.Counter
+	wsConnections     *prometheus.GaugeVec // by game
+	wsPerGame         map[GameID]int       // open connections, to drop series of games nobody watches
+	wsLock            sync.Mutex           // protects wsPerGame
+	wsMessagesSent    prometheus.Counter
+	wsMessagesDropped prometheus.Counter
+	webhooksDropped   prometheus.Counter
+}
+
+// NewMetrics creates Metrics registered in a new registry together with Go runtime and process metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route and method, websocket connections excluded.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		games: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "games",
+			Help:      "Games that exist on the server and are not archived.",
+		}),
+		players: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "players",
+			Help:      "Registered players.",
+		}),
+		votes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "votes_total",
+			Help:      "Votes cast in all games.",
+		}),
+		wsPerGame: map[GameID]int{},
+		wsConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ws_connections",
+			Help:      "Open websocket connections by game.",
+		}, []string{"game"}),
+		wsMessagesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "ws_messages_sent_total",
+			Help:      "Events written to websocket connections.",
+		}),
+		wsMessagesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "ws_messages_dropped_total",
+			Help:      "Events dropped because a subscriber couldn't keep up.",
+		}),
+		webhooksDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "webhook_deliveries_dropped_total",
+			Help:      "Webhook deliveries failed without being sent because too many were waiting.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.games,
+		m.players,
+		m.votes,
+		m.wsConnections,
+		m.wsMessagesSent,
+		m.wsMessagesDropped,
+		m.webhooksDropped,
+	)
+	return m
+}
+
+// Handler serves the metrics in Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// middleware counts requests and measures their latency. Routes are taken from the router, so IDs in paths don't
+// make a new series each.
+func (m *Metrics) middleware(c *gin.Context) {
+	if m == nil {
+		c.Next()
+		return
+	}
+	start := time.Now()
+	websocket := c.IsWebsocket()
+	c.Next()
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+	if !websocket { // the connection is served until it's closed, so the latency means nothing
+		m.requestDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) setGames(n int) {
+	if m != nil {
+		m.games.Set(float64(n))
+	}
+}
+
+func (m *Metrics) gameCreated() {
+	if m != nil {
+		m.games.Inc()
+	}
+}
+
+// gameArchived is gameDeleted for a game that became read-only, but still exists.
+func (m *Metrics) gameArchived() {
+	if m != nil {
+		m.games.Dec()
+	}
+}
+
+func (m *Metrics) gameDeleted() {
+	if m != nil {
+		m.games.Dec()
+	}
+}
+
+func (m *Metrics) setPlayers(n int) {
+	if m != nil {
+		m.players.Set(float64(n))
+	}
+}
+
+func (m *Metrics) playerRegistered() {
+	if m != nil {
+		m.players.Inc()
+	}
+}
+
+func (m *Metrics) voteCast() {
+	if m != nil {
+		m.votes.Inc()
+	}
+}
+
+func (m *Metrics) wsConnected(gameID GameID) {
+	if m == nil {
+		return
+	}
+	m.wsLock.Lock()
+	defer m.wsLock.Unlock()
+	m.wsPerGame[gameID]++
+	m.wsConnections.WithLabelValues(gameLabel(gameID)).Set(float64(m.wsPerGame[gameID]))
+}
+
+func (m *Metrics) wsDisconnected(gameID GameID) {
+	if m == nil {
+		return
+	}
+	m.wsLock.Lock()
+	defer m.wsLock.Unlock()
+	m.wsPerGame[gameID]--
+	if m.wsPerGame[gameID] > 0 {
+		m.wsConnections.WithLabelValues(gameLabel(gameID)).Set(float64(m.wsPerGame[gameID]))
+		return
+	}
+	delete(m.wsPerGame, gameID)
+	m.wsConnections.DeleteLabelValues(gameLabel(gameID))
+}
+
+func (m *Metrics) wsMessageSent() {
+	if m != nil {
+		m.wsMessagesSent.Inc()
+	}
+}
+
+func (m *Metrics) wsMessageDropped() {
+	if m != nil {
+		m.wsMessagesDropped.Inc()
+	}
+}
+
+func (m *Metrics) webhookDeliveryDropped() {
+	if m != nil {
+		m.webhooksDropped.Inc()
+	}
+}
+
+func gameLabel(gameID GameID) string {
+	return strconv.FormatUint(uint64(gameID), 10)
+}
@@ -0,0 +1,108 @@
+package game_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.MetricsAddr = "localhost:9090"
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	secondID := createDefaultGame(t, creator)
+	voter := createUser(t)
+	join(t, voter, gameID)
+	conn := dialGame(t, gameID)
+	readEvent(t, conn) // snapshot
+	vote(t, voter, "5", gameID)
+	readEvent(t, conn)
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", secondID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, err := http.Get(fullPath("/api/games/100500"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	metrics := scrapeMetrics(t)
+	require.Contains(t, metrics, "gpoker_games 1\n")
+	require.Contains(t, metrics, "gpoker_players 2\n")
+	require.Contains(t, metrics, "gpoker_votes_total 1\n")
+	require.Contains(t, metrics, fmt.Sprintf("gpoker_ws_connections{game=\"%d\"} 1\n", gameID))
+	require.Contains(t, metrics, "gpoker_ws_messages_sent_total 2\n")
+	require.Contains(t, metrics, "gpoker_ws_messages_dropped_total 0\n")
+	require.Contains(t, metrics, "gpoker_webhook_deliveries_dropped_total 0\n")
+	require.Contains(t, metrics, `gpoker_http_requests_total{method="POST",route="/api/games/:gameId/vote",status="200"} 1`)
+	require.Contains(t, metrics, `gpoker_http_requests_total{method="GET",route="/api/games/:gameId",status="404"} 1`)
+	require.Contains(t, metrics, `gpoker_http_request_duration_seconds_count{method="POST",route="/api/games"} 2`)
+	require.Contains(t, metrics, "go_goroutines")
+
+	// metrics are not served by the API
+	resp, err = http.Get(fullPath("/metrics"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestMetricsCountOnlyActiveGames(t *testing.T) {
+	clock := newFakeClock()
+	cfg := expiryConfig(clock, game.GameExpiryArchive)
+	cfg.MetricsAddr = "localhost:9090"
+	cfg.Storage = game.StorageConfig{Backend: game.StorageFile, Path: filepath.Join(t.TempDir(), "gpoker.log")}
+	cfg.AuthSecret = "s3cret"
+	srv := startServerWithConfig(t, cfg)
+	waitForServer(t)
+	creator := createUser(t)
+	idleID := createDefaultGame(t, creator)
+	activeID := createDefaultGame(t, creator)
+	require.Contains(t, scrapeMetrics(t), "gpoker_games 2\n")
+
+	clock.Advance(testGameTTL / 2)
+	vote(t, creator, "5", activeID)
+	clock.Advance(testGameTTL/2 + time.Second)
+	require.Eventually(t, func() bool { return getGame(t, idleID).Archived }, 2*time.Second, 5*time.Millisecond)
+	require.Contains(t, scrapeMetrics(t), "gpoker_games 1\n")
+
+	// archived games are not counted after a restart either
+	require.NoError(t, srv.Stop(context.Background()))
+	srv = startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	require.Contains(t, scrapeMetrics(t), "gpoker_games 1\n")
+
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", idleID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Contains(t, scrapeMetrics(t), "gpoker_games 1\n")
+}
+
+func TestMetricsDisabledByDefault(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	_, err := http.Get("http://localhost:9090/metrics")
+	require.Error(t, err)
+}
+
+func scrapeMetrics(t *testing.T) string {
+	var resp *http.Response
+	require.Eventually(t, func() bool { // the metrics listener starts independently of the API
+		var err error
+		resp, err = http.Get("http://localhost:9090/metrics")
+		return err == nil
+	}, 4*time.Second, 10*time.Millisecond)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,97 @@
+package game
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// presence of a player in a game. A player is online while at least one of their websocket connections is open.
+type presence struct {
+	connections int
+	lastSeen    time.Time // when the player connected, answered a ping or disconnected
+}
+
+// PresenceChanged is a payload of EventPresenceChanged.
+type PresenceChanged struct {
+	PlayerID PlayerID  `json:"playerId"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Connect marks a player as connected to the game. Every Connect must be followed by Disconnect once the connection
+// is closed. Players that are not in the game are tracked too, so they are online as soon as they join.
+func (d *Dealer) Connect(gameID GameID, playerID PlayerID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if game.Presence == nil {
+		game.Presence = make(map[PlayerID]*presence)
+	}
+	p, ok := game.Presence[playerID]
+	if !ok {
+		p = &presence{}
+		game.Presence[playerID] = p
+	}
+	p.connections++
+	p.lastSeen = d.clock.Now()
+	if p.connections == 1 {
+		d.presenceChanged(game, playerID, p)
+	}
+	return nil
+}
+
+// Disconnect marks one connection of a player to the game as closed. The player goes offline with the last one, so
+// if the game ignores offline players the round may be revealed.
+func (d *Dealer) Disconnect(gameID GameID, playerID PlayerID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	p, ok := game.Presence[playerID]
+	if !ok || p.connections == 0 {
+		return nil
+	}
+	p.connections--
+	p.lastSeen = d.clock.Now()
+	if p.connections > 0 {
+		return nil
+	}
+	d.presenceChanged(game, playerID, p)
+	d.autoReveal(game)
+	return nil
+}
+
+// Seen records that a connected player is still there, e.g. because they answered a ping.
+func (d *Dealer) Seen(gameID GameID, playerID PlayerID) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if p, ok := game.Presence[playerID]; ok {
+		p.lastSeen = d.clock.Now()
+	}
+}
+
+// presenceChanged lets subscribers know that a player of the game went online or offline.
+func (d *Dealer) presenceChanged(game *Poker, playerID PlayerID, p *presence) {
+	if _, ok := game.Players[playerID]; !ok {
+		return
+	}
+	online := p.connections > 0
+	d.log.WithFields(logrus.Fields{"game_id": game.ID, "player_id": playerID, "online": online}).Debug("Presence changed")
+	d.hub.Publish(NewEvent(EventPresenceChanged, game.ID, PresenceChanged{
+		PlayerID: playerID,
+		Online:   online,
+		LastSeen: p.lastSeen,
+	}))
+}
+
+// online tells whether the player has an open connection to the game.
+func online(game *Poker, playerID PlayerID) bool {
+	p, ok := game.Presence[playerID]
+	return ok && p.connections > 0
+}
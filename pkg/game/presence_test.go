@@ -0,0 +1,151 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPresence(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	watcher := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, watcher).Type)
+
+	poker := getGame(t, gameID)
+	for _, player := range poker.Players {
+		require.False(t, player.Online)
+		require.Nil(t, player.LastSeen)
+	}
+
+	conn := dialGameAs(t, voter.Token, gameID, http.StatusSwitchingProtocols)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+	changed := readPresenceChanged(t, watcher)
+	require.Equal(t, voter.Player.ID, changed.PlayerID)
+	require.True(t, changed.Online)
+	voterResponse := findPlayer(t, getGame(t, gameID), voter.Player.ID)
+	require.True(t, voterResponse.Online)
+	require.NotNil(t, voterResponse.LastSeen)
+	require.False(t, findPlayer(t, getGame(t, gameID), creator.Player.ID).Online)
+
+	// a second connection of the same player changes nothing, the player goes offline with the last one
+	second := dialGameAs(t, voter.Token, gameID, http.StatusSwitchingProtocols)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, second).Type)
+	require.NoError(t, second.Close())
+	require.NoError(t, conn.Close())
+	changed = readPresenceChanged(t, watcher)
+	require.Equal(t, voter.Player.ID, changed.PlayerID)
+	require.False(t, changed.Online)
+	voterResponse = findPlayer(t, getGame(t, gameID), voter.Player.ID)
+	require.False(t, voterResponse.Online)
+	require.True(t, voterResponse.LastSeen.Equal(changed.LastSeen))
+
+	dialGameAs(t, "invalid", gameID, http.StatusUnauthorized)
+}
+
+func TestIgnoreOffline(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	conn := dialGameAs(t, voter.Token, gameID, http.StatusSwitchingProtocols)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+
+	ignoreOffline, autoReveal := true, true
+	resp := doJSON(t, creator.Token, http.MethodPatch, fmt.Sprintf("/api/games/%d/settings", gameID),
+		game.GameSettingsRequest{AutoReveal: &autoReveal, IgnoreOffline: &ignoreOffline})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	require.True(t, poker.Settings.IgnoreOffline)
+
+	// the creator is offline and not waited for, the voter is online
+	vote(t, creator, "5", gameID)
+	require.Equal(t, game.RoundVoting, getGame(t, gameID).State)
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool {
+		return getGame(t, gameID).State == game.RoundRevealed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPing(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.WSPingPeriod = 50 * time.Millisecond
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	online := func() bool { return findPlayer(t, getGame(t, gameID), creator.Player.ID).Online }
+
+	// pongs are sent only while reading
+	conn := dialGameAs(t, creator.Token, gameID, http.StatusSwitchingProtocols)
+	require.Eventually(t, online, time.Second, 10*time.Millisecond)
+	pings := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	firstSeen := *findPlayer(t, getGame(t, gameID), creator.Player.ID).LastSeen
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatal("no ping")
+		}
+	}
+	require.Eventually(t, func() bool {
+		return findPlayer(t, getGame(t, gameID), creator.Player.ID).LastSeen.After(firstSeen)
+	}, time.Second, 10*time.Millisecond)
+
+	// a client that doesn't answer is dropped
+	dialGameAs(t, creator.Token, gameID, http.StatusSwitchingProtocols)
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool { return !online() }, time.Second, 10*time.Millisecond)
+}
+
+// dialGameAs connects to the websocket of a game with a session token in the query string.
+func dialGameAs(t *testing.T, token string, gameID game.GameID, expectedStatus int) *websocket.Conn {
+	url := fmt.Sprintf("ws://localhost:8080/ws/games/%d?token=%s", gameID, token)
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{})
+	require.Equal(t, expectedStatus, resp.StatusCode)
+	if expectedStatus != http.StatusSwitchingProtocols {
+		require.Error(t, err)
+		return nil
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readPresenceChanged(t *testing.T, conn *websocket.Conn) game.PresenceChanged {
+	event := readEvent(t, conn)
+	require.Equal(t, game.EventPresenceChanged, event.Type)
+	var changed game.PresenceChanged
+	require.NoError(t, json.Unmarshal(event.Payload, &changed))
+	return changed
+}
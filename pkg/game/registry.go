@@ -18,23 +18,38 @@ type PlayerRegistry struct {
 	nextPlayerID PlayerID
 	players      map[PlayerID]Player
 	lock         sync.RWMutex // locks nextPlayerID and players
+	store        PlayerStore  // every registered player is saved here
+	metrics      *Metrics     // nil if not instrumented
 }
 
+// NewPlayerRegistry creates a registry that keeps players only in memory.
 func NewPlayerRegistry() *PlayerRegistry {
 	return &PlayerRegistry{
 		nextPlayerID: 1,
 		players:      map[PlayerID]Player{},
 		lock:         sync.RWMutex{},
+		store:        MemoryStore{},
 	}
 }
 
+// NewPlayerRegistryWithStore creates a registry with players loaded from store. New players are saved to store.
+func NewPlayerRegistryWithStore(store PlayerStore) (*PlayerRegistry, error) {
+	players, nextPlayerID, err := store.LoadPlayers()
+	if err != nil {
+		return nil, err
+	}
+	registry := NewPlayerRegistry()
+	registry.store = store
+	registry.nextPlayerID = nextPlayerID
+	for _, player := range players {
+		registry.players[player.ID] = player
+	}
+	return registry, nil
+}
+
 // NewPlayerRegistryFromFile creates a registry and pre-populates players from a provided file.
 func NewPlayerRegistryFromFile(filepath string) (*PlayerRegistry, error) {
-	registry := &PlayerRegistry{
-		nextPlayerID: 1,
-		players:      map[PlayerID]Player{},
-		lock:         sync.RWMutex{},
-	}
+	registry := NewPlayerRegistry()
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, err
@@ -44,22 +59,40 @@ func NewPlayerRegistryFromFile(filepath string) (*PlayerRegistry, error) {
 		return nil, err
 	}
 	for _, name := range names {
-		registry.Register(name)
+		if _, err = registry.Register(name); err != nil {
+			return nil, err
+		}
 	}
 	return registry, nil
 }
 
 // Register adds a new player to registry.
-func (r *PlayerRegistry) Register(name string) Player {
+func (r *PlayerRegistry) Register(name string) (Player, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	newPlayer := Player{
 		ID:   r.nextPlayerID,
 		Name: name,
 	}
-	r.players[r.nextPlayerID] = newPlayer
+	// The ID is saved before the player, so it is never reused even if saving the player fails.
+	if err := r.store.SaveNextPlayerID(r.nextPlayerID + 1); err != nil {
+		return Player{}, err
+	}
 	r.nextPlayerID++
-	return newPlayer
+	if err := r.store.SavePlayer(newPlayer); err != nil {
+		return Player{}, err
+	}
+	r.players[newPlayer.ID] = newPlayer
+	r.metrics.playerRegistered()
+	return newPlayer, nil
+}
+
+// instrument starts recording metrics of players. It must be called before the registry is used.
+func (r *PlayerRegistry) instrument(metrics *Metrics) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = metrics
+	metrics.setPlayers(len(r.players))
 }
 
 // Get player from registry if it exists.
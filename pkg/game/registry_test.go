@@ -9,21 +9,25 @@ import (
 func TestCreatePlayer(t *testing.T) {
 	registry := game.NewPlayerRegistry()
 	name := "bobby"
-	player := registry.Register(name)
+	player, err := registry.Register(name)
+	require.NoError(t, err)
 	require.Equal(t, name, player.Name)
 }
 
 func TestPlayersHaveUniqueIDs(t *testing.T) {
 	registry := game.NewPlayerRegistry()
-	player1 := registry.Register("bobby")
-	player2 := registry.Register("bobby")
+	player1, err := registry.Register("bobby")
+	require.NoError(t, err)
+	player2, err := registry.Register("bobby")
+	require.NoError(t, err)
 	require.NotEqual(t, player1, player2)
 }
 
 func TestGetPlayer(t *testing.T) {
 	registry := game.NewPlayerRegistry()
 	name := "bobby"
-	registerPlayer := registry.Register(name)
+	registerPlayer, err := registry.Register(name)
+	require.NoError(t, err)
 	getPlayer, ok := registry.Get(registerPlayer.ID)
 	require.True(t, ok)
 	require.Equal(t, registerPlayer, getPlayer)
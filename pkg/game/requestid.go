@@ -0,0 +1,39 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDKey       = "gpoker.requestID" // gin.Context key of the request ID
+	maxRequestIDLength = 128
+)
+
+// assignRequestID is a middleware that gives every request an ID, so a client can refer to it when reporting a
+// problem. An ID sent by the client, e.g. by a proxy in front of the server, is kept. The ID is sent back in the
+// X-Request-ID header and in error responses.
+func assignRequestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = newRequestID()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+// requestID returns the ID given to the request by assignRequestID.
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
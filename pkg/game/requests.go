@@ -1,23 +1,99 @@
 package game
 
-// CreatePokerRequest to start a game.
+// CreatePokerRequest to start a game. The authenticated player becomes the creator. Fibonacci deck is used if Deck
+// is not set, Cards are needed only for a custom deck.
 type CreatePokerRequest struct {
-	GameName  string   `json:"gameName" binding:"required"`
-	CreatorID PlayerID `json:"creatorId" binding:"required"`
+	GameName string   `json:"gameName" binding:"required"`
+	Deck     DeckType `json:"deck,omitempty"`
+	Cards    []Vote   `json:"cards,omitempty"`
 }
 
-// JoinPokerRequest to join a game.
+// JoinPokerRequest to join a game as the authenticated player. Role is either RoleVoter, which is the default, or
+// RoleObserver.
 type JoinPokerRequest struct {
+	Role Role `json:"role,omitempty"`
+}
+
+// RenameGameRequest changes the name of a game.
+type RenameGameRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// GameSettingsRequest changes settings of a game. Only the settings that are set are changed.
+type GameSettingsRequest struct {
+	AutoReveal    *bool `json:"autoReveal,omitempty"`
+	IgnoreOffline *bool `json:"ignoreOffline,omitempty"`
+}
+
+// TransferFacilitatorRequest passes the facilitator role to another player of the game.
+type TransferFacilitatorRequest struct {
 	PlayerID PlayerID `json:"playerId" binding:"required"`
 }
 
-// VoteRequest for player's vote.
+// VoteRequest for the authenticated player's vote.
 type VoteRequest struct {
-	PlayerID PlayerID `json:"playerId" binding:"required"`
-	Vote     Vote     `json:"Vote" binding:"required"`
+	Vote Vote `json:"Vote" binding:"required"`
 }
 
 // RegisterUserRequest to add new user. We don't need passwords for now.
 type RegisterUserRequest struct {
 	Name string `json:"name" binding:"required"`
 }
+
+// StoryRequest to add a story to a game's backlog.
+type StoryRequest struct {
+	Key         string `json:"key"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Link        string `json:"link,omitempty" binding:"omitempty,url"`
+}
+
+// ReorderStoriesRequest sets a new order of a game's backlog. It must list every story of the game.
+type ReorderStoriesRequest struct {
+	StoryIDs []StoryID `json:"storyIds" binding:"required"`
+}
+
+// TimerRequest starts a countdown of the current round. OnExpiry is TimerActionNone if not set.
+type TimerRequest struct {
+	Seconds  int         `json:"seconds" binding:"required,min=1,max=3600"`
+	OnExpiry TimerAction `json:"onExpiry,omitempty" binding:"omitempty,oneof=none reveal close"`
+}
+
+// EstimateRequest sets the final estimate of a story.
+type EstimateRequest struct {
+	Estimate Vote `json:"estimate" binding:"required"`
+}
+
+// ListGamesRequest selects a page of games. It's read from the query string. All filters are optional, Status is
+// GameStatusActive and Sort is GameSortName by default. Sort can be prefixed with "-" for descending order.
+type ListGamesRequest struct {
+	Name      string     `form:"name"`      // case-insensitive part of the name
+	CreatedBy PlayerID   `form:"createdBy"` // only games created by this player
+	Member    PlayerID   `form:"member"`    // only games this player is in
+	Status    GameStatus `form:"status" binding:"omitempty,oneof=active archived all"`
+	Sort      GameSort   `form:"sort" binding:"omitempty,oneof=name -name created -created activity -activity"`
+	Limit     int        `form:"limit" binding:"omitempty,min=1,max=200"` // DefaultGameListLimit if not set
+	Cursor    string     `form:"cursor"`                                  // GameListResponse.NextCursor of the previous page
+}
+
+// ImportStoriesRequest is the query of POST /api/games/:gameId/stories/import. The column names apply only to CSV,
+// DefaultStoryColumns are used for those that are not set.
+type ImportStoriesRequest struct {
+	Mode              ImportMode `form:"mode" binding:"omitempty,oneof=append replace"` // ImportAppend if not set
+	KeyColumn         string     `form:"keyColumn"`
+	TitleColumn       string     `form:"titleColumn"`
+	DescriptionColumn string     `form:"descriptionColumn"`
+	LinkColumn        string     `form:"linkColumn"`
+}
+
+// ExportRequest is the query of GET /api/games/:gameId/export.
+type ExportRequest struct {
+	Format ExportFormat `form:"format" binding:"omitempty,oneof=csv json md"` // ExportJSON if not set
+}
+
+// WebhookRequest adds a webhook to a game. See WebhookTarget for the fields.
+type WebhookRequest struct {
+	URL    string      `json:"url" binding:"required,url"`
+	Secret string      `json:"secret"` // generated if not set
+	Events []EventType `json:"events"`
+}
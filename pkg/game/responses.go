@@ -1,18 +1,96 @@
 package game
 
+import "time"
+
 type GameResponse struct {
 	ID      GameID           `json:"id"`
+	Code    string           `json:"code,omitempty"` // join code to share with other players, only they see it
 	Name    string           `json:"name"`
+	State   RoundState       `json:"state"`
+	Deck    Deck             `json:"deck"`
 	Players []PlayerResponse `json:"players"`
+	Stats   *RoundStats      `json:"stats,omitempty"` // only for a revealed round
+
+	Stories        []StoryResponse `json:"stories"`
+	CurrentStoryID StoryID         `json:"currentStoryId,omitempty"`
+	Rounds         []Round         `json:"rounds"` // completed rounds that were not about any story
+
+	CreatedBy    PlayerID     `json:"createdBy,omitempty"` // not set for games created before creators were recorded
+	CreatedAt    time.Time    `json:"createdAt"`
+	LastActivity time.Time    `json:"lastActivity"`
+	Archived     bool         `json:"archived"` // the game expired and is read-only now
+	Settings     GameSettings `json:"settings"`
+
+	Timer *RoundTimer `json:"timer,omitempty"` // only while a countdown of the round runs
 }
 
+// StoryResponse is a story with its completed rounds.
+type StoryResponse struct {
+	Story
+	Rounds []Round `json:"rounds"`
+}
+
+// GameListEntry is a summary of a game in GameListResponse.
 type GameListEntry struct {
-	ID   GameID `json:"id"`
-	Name string `json:"name"`
+	ID           GameID     `json:"id"`
+	Name         string     `json:"name"`
+	PlayerCount  int        `json:"playerCount"`
+	CreatedBy    PlayerID   `json:"createdBy,omitempty"` // not set for games created before creators were recorded
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastActivity time.Time  `json:"lastActivity"`
+	State        RoundState `json:"state"`
+	Archived     bool       `json:"archived"`
+}
+
+// GameListResponse is a page of games. NextCursor is set if there are more games, pass it as ListGamesRequest.Cursor
+// to get them.
+type GameListResponse struct {
+	Games      []GameListEntry `json:"games"`
+	NextCursor string          `json:"nextCursor,omitempty"`
 }
 
+// hideCode removes the join code unless the player is in the game. Anyone can read games, but only their players
+// should be able to invite others; IDs of games are sequential, so codes would be easy to collect otherwise.
+func (r *GameResponse) hideCode(player Player, authenticated bool) {
+	if authenticated {
+		for _, p := range r.Players {
+			if p.ID == player.ID {
+				return
+			}
+		}
+	}
+	r.Code = ""
+}
+
+// PlayerResponse describes a player in a game. Vote is set only after the round is revealed, before that Voted tells
+// whether the player has voted already. Online is set while the player is connected to the game's websocket.
 type PlayerResponse struct {
-	ID   PlayerID `json:"id"`
-	Name string   `json:"name"`
-	Vote Vote     `json:"vote"`
+	ID       PlayerID   `json:"id"`
+	Name     string     `json:"name"`
+	Role     Role       `json:"role"`
+	Voted    bool       `json:"voted"`
+	Vote     Vote       `json:"vote,omitempty"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"` // not set if the player hasn't connected since the server started
+}
+
+// SignupResponse contains the registered player and a token to authenticate as this player.
+type SignupResponse struct {
+	Player
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ErrorResponse is the body of every error response of the API.
+type ErrorResponse struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"` // what is wrong with particular fields of the request
+	RequestID string       `json:"requestId"`
+}
+
+// FieldError describes a problem with a single field of a request body.
+type FieldError struct {
+	Field   string `json:"field"` // path to the field in JSON, e.g. "gameName"
+	Message string `json:"message"`
 }
@@ -0,0 +1,134 @@
+package game
+
+import "errors"
+
+var ErrNotFacilitator = errors.New("only the facilitator of the game can do this")
+var ErrObserverCannotVote = errors.New("observers can't vote")
+var ErrInvalidRole = errors.New("role must be either voter or observer")
+
+// Role of a player in a game.
+type Role string
+
+const (
+	// RoleFacilitator runs the game: reveals votes, starts rounds, manages the backlog and can vote. Every game has
+	// exactly one facilitator, its creator unless the role was transferred.
+	RoleFacilitator Role = "facilitator"
+	RoleVoter       Role = "voter"
+	RoleObserver    Role = "observer" // watches the game without voting
+)
+
+// RolesChanged is a payload of EventRolesChanged with every player whose role changed.
+type RolesChanged struct {
+	Players []PlayerResponse `json:"players"`
+}
+
+// GameRenamed is a payload of EventGameRenamed.
+type GameRenamed struct {
+	Name string `json:"name"`
+}
+
+// RenameGame changes the name of the game. Only the facilitator can rename it.
+func (d *Dealer) RenameGame(gameID GameID, playerID PlayerID, name string) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	game.Name = name
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.hub.Publish(NewEvent(EventGameRenamed, gameID, GameRenamed{Name: name}))
+	return gameToResponse(game), nil
+}
+
+// TransferFacilitator makes another player of the game its facilitator. The former facilitator becomes a voter.
+func (d *Dealer) TransferFacilitator(gameID GameID, playerID PlayerID, to PlayerID) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	successor, ok := game.Players[to]
+	if !ok {
+		return GameResponse{}, &NotInGameError{GameID: gameID, PlayerID: to}
+	}
+	if to == playerID {
+		return gameToResponse(game), nil
+	}
+	game.Roles[playerID] = RoleVoter
+	game.Roles[to] = RoleFacilitator
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.hub.Publish(NewEvent(EventRolesChanged, gameID, RolesChanged{Players: []PlayerResponse{
+		playerToResponse(game, game.Players[playerID]),
+		playerToResponse(game, successor),
+	}}))
+	return gameToResponse(game), nil
+}
+
+// checkFacilitator returns ForbiddenError with ErrNotFacilitator unless the player is the facilitator of the game.
+func checkFacilitator(game *Poker, playerID PlayerID) error {
+	if game.Roles[playerID] != RoleFacilitator {
+		return &ForbiddenError{PlayerID: playerID, Reason: ErrNotFacilitator}
+	}
+	return nil
+}
+
+// joinRole returns a role a player gets when joining the game with the desired role. The facilitator keeps their role
+// when joining again, so the game isn't left without one.
+func joinRole(game *Poker, playerID PlayerID, desired Role) (Role, error) {
+	switch desired {
+	case "", RoleVoter:
+		desired = RoleVoter
+	case RoleObserver:
+	default:
+		return "", ErrInvalidRole
+	}
+	if game.Roles[playerID] == RoleFacilitator {
+		return RoleFacilitator, nil
+	}
+	return desired, nil
+}
+
+// ensureRoles gives roles to players of a game saved before roles existed. Everyone becomes a voter and one of them
+// the facilitator.
+func ensureRoles(game *Poker) {
+	if game.Roles == nil {
+		game.Roles = make(map[PlayerID]Role, len(game.Players))
+	}
+	for id := range game.Players {
+		if _, ok := game.Roles[id]; !ok {
+			game.Roles[id] = RoleVoter
+		}
+	}
+	electFacilitator(game)
+}
+
+// electFacilitator makes sure a game with players has a facilitator. If there is none, the voter with the lowest ID
+// becomes one, or an observer if there are no voters. It returns the new facilitator
+// or 0 if the facilitator didn't change.
+func electFacilitator(game *Poker) PlayerID {
+	var elected PlayerID
+	for id, role := range game.Roles {
+		switch {
+		case role == RoleFacilitator:
+			return 0
+		case elected == 0,
+			role == game.Roles[elected] && id < elected,
+			role == RoleVoter && game.Roles[elected] == RoleObserver:
+			elected = id
+		}
+	}
+	if elected != 0 {
+		game.Roles[elected] = RoleFacilitator
+	}
+	return elected
+}
@@ -0,0 +1,187 @@
+package game_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestObserverCannotVote(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	player := createUser(t)
+
+	joinAs(t, player.Token, gameID, game.RoleObserver, http.StatusOK)
+	require.Equal(t, game.RoleObserver, findPlayer(t, getGame(t, gameID), player.ID).Role)
+	voteExpect(t, player.Token, "1", gameID, http.StatusForbidden)
+
+	joinAs(t, player.Token, gameID, game.RoleVoter, http.StatusOK)
+	vote(t, player, "1", gameID)
+	require.True(t, findPlayer(t, getGame(t, gameID), player.ID).Voted)
+
+	// the vote is dropped when the player becomes an observer
+	joinAs(t, player.Token, gameID, game.RoleObserver, http.StatusOK)
+	require.False(t, findPlayer(t, getGame(t, gameID), player.ID).Voted)
+}
+
+func TestJoinWithInvalidRole(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	gameID := createDefaultGame(t, createUser(t))
+	player := createUser(t)
+
+	for _, role := range []game.Role{game.RoleFacilitator, "admin"} {
+		joinAs(t, player.Token, gameID, role, http.StatusBadRequest)
+	}
+	require.Len(t, getGame(t, gameID).Players, 1)
+}
+
+func TestFacilitatorKeepsRoleOnJoin(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+
+	joinAs(t, creator.Token, gameID, game.RoleObserver, http.StatusOK)
+	require.Equal(t, game.RoleFacilitator, findPlayer(t, getGame(t, gameID), creator.ID).Role)
+}
+
+func TestOnlyFacilitatorManagesGame(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	story := addStory(t, creator.Token, gameID, game.StoryRequest{Title: "Login"})
+	voter := createUser(t)
+	join(t, voter, gameID)
+	outsider := createUser(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{name: "reveal", method: http.MethodPost, path: "/api/games/%d/reveal"},
+		{name: "new round", method: http.MethodPost, path: "/api/games/%d/round"},
+		{name: "rename", method: http.MethodPut, path: "/api/games/%d", body: game.RenameGameRequest{Name: "mine"}},
+		{
+			name:   "transfer facilitator",
+			method: http.MethodPut,
+			path:   "/api/games/%d/facilitator",
+			body:   game.TransferFacilitatorRequest{PlayerID: voter.ID},
+		},
+		{name: "add story", method: http.MethodPost, path: "/api/games/%d/stories", body: game.StoryRequest{Title: "a"}},
+		{
+			name:   "reorder stories",
+			method: http.MethodPut,
+			path:   "/api/games/%d/stories",
+			body:   game.ReorderStoriesRequest{StoryIDs: []game.StoryID{story.ID}},
+		},
+		{name: "remove story", method: http.MethodDelete, path: fmt.Sprintf("/api/games/%%d/stories/%d", story.ID)},
+		{name: "next story", method: http.MethodPost, path: "/api/games/%d/stories/next"},
+		{
+			name:   "set estimate",
+			method: http.MethodPut,
+			path:   fmt.Sprintf("/api/games/%%d/stories/%d/estimate", story.ID),
+			body:   game.EstimateRequest{Estimate: "1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, player := range []game.SignupResponse{voter, outsider} {
+				resp := doJSON(t, player.Token, test.method, fmt.Sprintf(test.path, gameID), test.body)
+				require.Equal(t, http.StatusForbidden, resp.StatusCode)
+			}
+		})
+	}
+	poker := getGame(t, gameID)
+	require.Equal(t, game.RoundVoting, poker.State)
+	require.Equal(t, []game.StoryResponse{{Story: story, Rounds: []game.Round{}}}, poker.Stories)
+}
+
+func TestRenameGame(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+
+	resp := doJSON(t, creator.Token, http.MethodPut, fmt.Sprintf("/api/games/%d", gameID), game.RenameGameRequest{Name: "Sprint 42"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Sprint 42", getGame(t, gameID).Name)
+
+	resp = doJSON(t, creator.Token, http.MethodPut, fmt.Sprintf("/api/games/%d", gameID), game.RenameGameRequest{})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodPut, "/api/games/100", game.RenameGameRequest{Name: "a"})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTransferFacilitator(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	observer := createUser(t)
+	joinAs(t, observer.Token, gameID, game.RoleObserver, http.StatusOK)
+	path := fmt.Sprintf("/api/games/%d/facilitator", gameID)
+
+	resp := doJSON(t, creator.Token, http.MethodPut, path, game.TransferFacilitatorRequest{PlayerID: createUser(t).ID})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode) // not in the game
+
+	resp = doJSON(t, creator.Token, http.MethodPut, path, game.TransferFacilitatorRequest{PlayerID: observer.ID})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	poker := getGame(t, gameID)
+	require.Equal(t, game.RoleVoter, findPlayer(t, poker, creator.ID).Role)
+	require.Equal(t, game.RoleFacilitator, findPlayer(t, poker, observer.ID).Role)
+
+	resp = doJSON(t, creator.Token, http.MethodPost, fmt.Sprintf("/api/games/%d/reveal", gameID), nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	vote(t, observer, "3", gameID) // the facilitator can vote
+	reveal(t, observer.Token, gameID)
+}
+
+func TestRolesOfGamesSavedBeforeRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpoker.log")
+	log := `{"op":"next_game_id","gameId":2}
+{"op":"save_game","game":{"id":1,"name":"old","players":{"3":{"id":3,"name":"bobby"},"2":{"id":2,"name":"alice"}},"votes":{},"state":"voting","deck":{"type":"fibonacci","cards":["1"]}}}
+`
+	require.NoError(t, os.WriteFile(path, []byte(log), 0o600))
+	store, err := game.OpenFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	dealer, err := game.NewDealerWithStore(store)
+	require.NoError(t, err)
+
+	poker, ok := dealer.GetGame(1)
+	require.True(t, ok)
+	require.Equal(t, game.RoleFacilitator, findPlayer(t, poker, 2).Role)
+	require.Equal(t, game.RoleVoter, findPlayer(t, poker, 3).Role)
+}
+
+func joinAs(t *testing.T, token string, gameID game.GameID, role game.Role, expectedResponseCode int) {
+	resp := doJSON(t, token, http.MethodPut, fmt.Sprintf("/api/games/%d/join", gameID), game.JoinPokerRequest{Role: role})
+	require.Equal(t, expectedResponseCode, resp.StatusCode)
+}
+
+func findPlayer(t *testing.T, poker game.GameResponse, id game.PlayerID) game.PlayerResponse {
+	for _, player := range poker.Players {
+		if player.ID == id {
+			return player
+		}
+	}
+	t.Fatalf("player %d not found in game %d", id, poker.ID)
+	return game.PlayerResponse{}
+}
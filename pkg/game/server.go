@@ -1,13 +1,14 @@
 package game
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"log"
+	"github.com/sirupsen/logrus"
+	"io"
 	"net/http"
 	"strconv"
 	"sync"
@@ -15,202 +16,906 @@ import (
 )
 
 var ErrBadGameID = errors.New("game ID is not provided or is incorrect")
+var ErrBadStoryID = errors.New("story ID is not provided or is incorrect")
+var ErrBadPlayerID = errors.New("player ID is not provided or is incorrect")
+var ErrBadWebhookID = errors.New("webhook ID is not provided or is incorrect")
+
+// wsWriteTimeout limits how long we wait for a single message to be written to a websocket.
+const wsWriteTimeout = 10 * time.Second
+
+// maxImportSize limits the body of a story import to 1 MiB.
+const maxImportSize = 1 << 20
 
 // Server is a main game server
 type Server struct {
 	srv            *http.Server
+	metricsSrv     *http.Server // nil if metrics are disabled
+	metrics        *Metrics     // nil if metrics are disabled
+	log            *logrus.Logger
+	cfg            Config
+	stopJanitor    chan struct{}
+	janitorDone    chan struct{} // closed when the janitor returns, or right away if games don't expire
 	dealer         *Dealer
 	playerRegistry *PlayerRegistry
+	store          Store
+	sessions       *Sessions
+	upgrader       websocket.Upgrader
 
 	startOnce sync.Once
+	stopOnce  sync.Once
 }
 
-// NewServer creates a new Server.
-func NewServer() *Server {
-	app := gin.Default()
-	app.Use(cors.Default()) // CORS enabler for dev
-	dealer := NewDealer()
-	registry := NewPlayerRegistry()
+// NewServer creates a new Server configured by cfg.
+func NewServer(cfg Config) (*Server, error) {
+	logger, err := cfg.logger()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.GameTTL > 0 {
+		if err = cfg.GameExpiry.validate(); err != nil {
+			return nil, err
+		}
+		if cfg.JanitorPeriod <= 0 {
+			return nil, fmt.Errorf("janitor period must be positive, got %s", cfg.JanitorPeriod)
+		}
+	}
+	if cfg.WSPingPeriod <= 0 {
+		return nil, fmt.Errorf("websocket ping period must be positive, got %s", cfg.WSPingPeriod)
+	}
+	if cfg.SessionTTL <= 0 {
+		return nil, fmt.Errorf("session TTL must be positive, got %s", cfg.SessionTTL)
+	}
+	if err = cfg.Webhooks.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Storage.Backend == StorageFile && cfg.AuthSecret == "" {
+		// a random secret would invalidate saved sessions on every restart
+		return nil, fmt.Errorf("auth secret is required for %q storage", StorageFile)
+	}
+	store, err := OpenStore(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	dealer, err := NewDealerWithStore(store)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	dealer.setLogger(logger)
+	if cfg.Clock != nil {
+		dealer.setClock(cfg.Clock)
+	}
+	registry, err := NewPlayerRegistryWithStore(store)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	sessions, err := NewSessionsWithStore([]byte(cfg.AuthSecret), cfg.SessionTTL, store)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	if cfg.GinMode != "" {
+		gin.SetMode(cfg.GinMode)
+	}
+	var metrics *Metrics
+	if cfg.MetricsAddr != "" {
+		metrics = NewMetrics()
+		dealer.instrument(metrics)
+		registry.instrument(metrics)
+	}
+	useJSONFieldNames()
+	app := gin.New()
+	app.Use(assignRequestID, logRequests(logger), gin.Recovery(), metrics.middleware, cfg.corsMiddleware())
+	app.NoRoute(func(c *gin.Context) {
+		abortWithError(c, errNoRoute)
+	})
 	srv := &Server{
 		srv: &http.Server{
-			Addr:    ":8080", // FIXME don't hardcode that
+			Addr:    cfg.Addr,
 			Handler: app,
 		},
+		metrics:        metrics,
+		log:            logger,
+		cfg:            cfg,
+		stopJanitor:    make(chan struct{}),
+		janitorDone:    make(chan struct{}),
 		dealer:         dealer,
 		playerRegistry: registry,
+		store:          store,
+		sessions:       sessions,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: 5 * time.Second,
+			ReadBufferSize:   1024,
+			WriteBufferSize:  1024,
+			Subprotocols:     nil,
+			CheckOrigin:      cfg.checkWSOrigin(),
+		},
 	}
 
 	app.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
 	app.POST("/api/signup", srv.signup)
-
-	app.POST("/api/games", srv.createGame)
 	app.GET("/api/games", srv.listGames)
 	app.GET("/api/games/:gameId", srv.getGame)
-	app.PUT("/api/games/:gameId/join", srv.joinGame)
-	app.POST("/api/games/:gameId/vote", srv.vote) // should it rather be put? patch?
+	app.GET("/api/games/:gameId/export", srv.exportGame)
+	app.GET("/api/join/:code", srv.getGameByCode)
+
+	// everything that changes games requires a session
+	authorized := app.Group("", srv.authenticate)
+	authorized.POST("/api/logout", srv.logout)
+	authorized.POST("/api/games", srv.createGame)
+	authorized.PUT("/api/games/:gameId", srv.renameGame)
+	authorized.DELETE("/api/games/:gameId", srv.deleteGame)
+	authorized.PATCH("/api/games/:gameId/settings", srv.updateSettings)
+	authorized.PUT("/api/games/:gameId/facilitator", srv.transferFacilitator)
+	authorized.PUT("/api/games/:gameId/join", srv.joinGame)
+	authorized.DELETE("/api/games/:gameId/join", srv.leaveGame)
+	authorized.PUT("/api/join/:code", srv.joinGameByCode)
+	authorized.DELETE("/api/games/:gameId/players/:playerId", srv.removePlayer)
+	authorized.POST("/api/games/:gameId/vote", srv.vote) // should it rather be put? patch?
+	authorized.POST("/api/games/:gameId/reveal", srv.reveal)
+	authorized.POST("/api/games/:gameId/round", srv.newRound)
+	authorized.POST("/api/games/:gameId/timer", srv.startTimer)
+	authorized.DELETE("/api/games/:gameId/timer", srv.stopTimer)
+
+	authorized.POST("/api/games/:gameId/stories", srv.addStory)
+	authorized.PUT("/api/games/:gameId/stories", srv.reorderStories)
+	authorized.POST("/api/games/:gameId/stories/import", srv.importStories)
+	authorized.DELETE("/api/games/:gameId/stories/:storyId", srv.removeStory)
+	authorized.POST("/api/games/:gameId/stories/next", srv.nextStory)
+	authorized.PUT("/api/games/:gameId/stories/:storyId/estimate", srv.setEstimate)
+
+	authorized.POST("/api/games/:gameId/webhooks", srv.addWebhook)
+	authorized.GET("/api/games/:gameId/webhooks", srv.listWebhooks)
+	authorized.DELETE("/api/games/:gameId/webhooks/:webhookId", srv.removeWebhook)
+	authorized.GET("/api/games/:gameId/webhooks/:webhookId/deliveries", srv.webhookDeliveries)
 
 	app.GET("/ws/games/:gameId", srv.serveWS)
-	return srv
+
+	admin := app.Group("", srv.authenticateAdmin)
+	admin.GET("/api/webhooks/:webhookId/deliveries", srv.globalWebhookDeliveries)
+
+	if metrics != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		srv.metricsSrv = &http.Server{
+			Addr:    cfg.MetricsAddr,
+			Handler: mux,
+		}
+	}
+	return srv, nil
 }
 
 // NewStartedServer creates a new Server and starts it.
-func NewStartedServer() *Server {
-	srv := NewServer()
+func NewStartedServer(cfg Config) (*Server, error) {
+	srv, err := NewServer(cfg)
+	if err != nil {
+		return nil, err
+	}
 	srv.Start()
-	return srv
+	return srv, nil
 }
 
 func (s *Server) Start() {
 	s.startOnce.Do(func() {
+		s.dealer.startWebhooks(s.cfg.Webhooks)
 		go func() {
 			if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("Server error = %s", err)
+				s.log.WithError(err).Fatal("Server failed")
 			}
 		}()
+		if s.cfg.GameTTL > 0 {
+			go s.runJanitor(s.cfg.GameTTL, s.cfg.JanitorPeriod, s.cfg.GameExpiry, s.stopJanitor, s.janitorDone)
+		} else {
+			close(s.janitorDone)
+		}
+		if s.metricsSrv != nil {
+			go func() {
+				if err := s.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					s.log.WithError(err).Fatal("Metrics server failed")
+				}
+			}()
+		}
 	})
 }
 
+// Stop stops expiring games, closes all game subscriptions, gracefully shuts down the server and closes the storage.
 func (s *Server) Stop(ctx context.Context) error {
-	return s.srv.Shutdown(ctx)
+	s.startOnce.Do(func() { close(s.janitorDone) }) // never started
+	s.stopOnce.Do(func() { close(s.stopJanitor) })
+	<-s.janitorDone
+	s.dealer.Close() // hijacked websocket connections are not handled by Shutdown
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	if s.metricsSrv != nil {
+		if err := s.metricsSrv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return s.store.Close()
+}
+
+// Logger returns the logger configured by Config, so programs running the server can log the same way.
+func (s *Server) Logger() logrus.FieldLogger {
+	return s.log
 }
 
 func (s *Server) signup(c *gin.Context) {
 	var req RegisterUserRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+	if !bindJSON(c, &req) {
+		return
+	}
+	player, err := s.playerRegistry.Register(req.Name)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	token, expiresAt, err := s.sessions.Issue(player.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &SignupResponse{
+		Player:    player,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *Server) logout(c *gin.Context) {
+	if err := s.sessions.Revoke(sessionToken(c)); err != nil {
+		abortWithError(c, err)
 		return
 	}
-	player := s.playerRegistry.Register(req.Name)
-	c.JSON(http.StatusOK, &player)
+	c.Status(http.StatusNoContent)
 }
 
 func (s *Server) createGame(c *gin.Context) {
 	var req CreatePokerRequest
-	if err := c.BindJSON(&req); err != nil {
-		_ = c.AbortWithError(http.StatusBadRequest, err)
+	if !bindJSON(c, &req) {
 		return
 	}
-	player, ok := s.playerRegistry.Get(req.CreatorID)
-	if !ok {
-		c.AbortWithStatus(http.StatusBadRequest)
+	deck, err := NewDeck(req.Deck, req.Cards)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
-	game, err := s.dealer.CreateGame(req.GameName, player)
+	game, err := s.dealer.CreateGame(req.GameName, currentPlayer(c), deck)
 	if err != nil {
-		_ = c.AbortWithError(http.StatusBadRequest, err)
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, &game)
 }
 
 func (s *Server) listGames(c *gin.Context) {
-	games := s.dealer.ListGameNames()
+	var req ListGamesRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+	games, err := s.dealer.ListGames(req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, &games)
 }
 
 func (s *Server) getGame(c *gin.Context) {
 	id, ok := ParamUint64(c, "gameId")
 	if !ok {
-		_ = c.AbortWithError(http.StatusBadRequest, ErrBadGameID)
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	player, authenticated, err := s.optionalPlayer(c)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 	poker, ok := s.dealer.GetGame(GameID(id))
 	if !ok {
-		_ = c.AbortWithError(http.StatusNotFound, errGameNotFound(GameID(id)))
+		abortWithError(c, &GameNotFoundError{GameID: GameID(id)})
+		return
+	}
+	poker.hideCode(player, authenticated)
+	c.JSON(http.StatusOK, &poker)
+}
+
+// exportGame answers with the results of a game as a file to download.
+func (s *Server) exportGame(c *gin.Context) {
+	id, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req ExportRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+	if req.Format == "" {
+		req.Format = ExportJSON
+	}
+	export, err := s.dealer.Export(GameID(id), req.Format)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="game-%d.%s"`, id, req.Format))
+	c.Data(http.StatusOK, req.Format.ContentType(), export)
+}
+
+func (s *Server) renameGame(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req RenameGameRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	poker, err := s.dealer.RenameGame(GameID(gameId), currentPlayer(c).ID, req.Name)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) transferFacilitator(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req TransferFacilitatorRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if _, ok = s.playerRegistry.Get(req.PlayerID); !ok {
+		abortWithError(c, &PlayerNotFoundError{PlayerID: req.PlayerID})
+		return
+	}
+	poker, err := s.dealer.TransferFacilitator(GameID(gameId), currentPlayer(c).ID, req.PlayerID)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, &poker)
 }
 
+func (s *Server) deleteGame(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	if err := s.dealer.DeleteGame(GameID(gameId), currentPlayer(c).ID); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (s *Server) joinGame(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	joinReq, ok := bindJoinRequest(c)
+	if !ok {
+		return
+	}
+	if err := s.dealer.JoinGame(GameID(gameId), currentPlayer(c), joinReq.Role); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *Server) getGameByCode(c *gin.Context) {
+	gameID, err := s.dealer.GameByCode(c.Param("code"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	poker, ok := s.dealer.GetGame(gameID)
+	if !ok { // deleted in between
+		abortWithError(c, &GameNotFoundError{GameID: gameID})
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+// joinGameByCode is like joinGame, but answers with the game, so the player learns its ID.
+func (s *Server) joinGameByCode(c *gin.Context) {
+	gameID, err := s.dealer.GameByCode(c.Param("code"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	joinReq, ok := bindJoinRequest(c)
+	if !ok {
+		return
+	}
+	if err = s.dealer.JoinGame(gameID, currentPlayer(c), joinReq.Role); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	poker, ok := s.dealer.GetGame(gameID)
+	if !ok {
+		abortWithError(c, &GameNotFoundError{GameID: gameID})
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+// bindJoinRequest reads an optional JoinPokerRequest, a player joins as a voter without it.
+func bindJoinRequest(c *gin.Context) (JoinPokerRequest, bool) {
 	var joinReq JoinPokerRequest
-	if err := c.BindJSON(&joinReq); err != nil {
-		_ = c.AbortWithError(http.StatusBadRequest, err)
+	if err := c.ShouldBindJSON(&joinReq); err != nil && !errors.Is(err, io.EOF) {
+		abortWithError(c, &bindingError{err: err})
+		return JoinPokerRequest{}, false
+	}
+	return joinReq, true
+}
+
+func (s *Server) leaveGame(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	if err := s.dealer.LeaveGame(GameID(gameId), currentPlayer(c).ID); err != nil {
+		abortWithError(c, err)
 		return
 	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) removePlayer(c *gin.Context) {
 	gameId, ok := ParamUint64(c, "gameId")
 	if !ok {
-		_ = c.AbortWithError(http.StatusBadRequest, ErrBadGameID) // TODO Should it be abort?
+		abortWithError(c, ErrBadGameID)
 		return
 	}
-	player, ok := s.playerRegistry.Get(joinReq.PlayerID)
+	playerId, ok := ParamUint64(c, "playerId")
 	if !ok {
-		_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("player with id %d not found", joinReq.PlayerID))
+		abortWithError(c, ErrBadPlayerID)
 		return
 	}
+	if _, ok = s.playerRegistry.Get(PlayerID(playerId)); !ok {
+		abortWithError(c, &PlayerNotFoundError{PlayerID: PlayerID(playerId)})
+		return
+	}
+	if err := s.dealer.RemovePlayer(GameID(gameId), currentPlayer(c).ID, PlayerID(playerId)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
 
-	err := s.dealer.JoinGame(GameID(gameId), player)
-
-	switch err {
-	case nil:
-		c.Status(http.StatusOK)
-	case ErrGameNotFound:
-		_ = c.AbortWithError(http.StatusBadRequest, errGameNotFound(GameID(gameId)))
-	default:
-		_ = c.AbortWithError(http.StatusInternalServerError, err)
+func (s *Server) updateSettings(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req GameSettingsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	poker, err := s.dealer.UpdateSettings(GameID(gameId), currentPlayer(c).ID, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, &poker)
 }
 
 func (s *Server) vote(c *gin.Context) {
 	gameId, ok := ParamUint64(c, "gameId")
 	if !ok {
-		c.AbortWithStatus(http.StatusBadRequest)
+		abortWithError(c, ErrBadGameID)
 		return
 	}
 	var voteReq VoteRequest
-	if err := c.BindJSON(&voteReq); err != nil {
-		_ = c.AbortWithError(http.StatusBadRequest, err) // TODO remove this later
-		return
-	}
-	err := s.dealer.Vote(GameID(gameId), voteReq)
-	switch err {
-	case nil:
-		c.Status(http.StatusOK)
-	case ErrGameNotFound:
-		_ = c.AbortWithError(http.StatusBadRequest, errGameNotFound(GameID(gameId)))
-	case ErrPlayerNotInGame:
-		_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("player %d not in game %d", voteReq.PlayerID, gameId))
+	if !bindJSON(c, &voteReq) {
+		return
+	}
+	if err := s.dealer.Vote(GameID(gameId), currentPlayer(c).ID, voteReq.Vote); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *Server) reveal(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	poker, err := s.dealer.Reveal(GameID(gameId), currentPlayer(c).ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) newRound(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	poker, err := s.dealer.NewRound(GameID(gameId), currentPlayer(c).ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) startTimer(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req TimerRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	duration := time.Duration(req.Seconds) * time.Second
+	poker, err := s.dealer.StartTimer(GameID(gameId), currentPlayer(c).ID, duration, req.OnExpiry)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) stopTimer(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	if err := s.dealer.StopTimer(GameID(gameId), currentPlayer(c).ID); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) addStory(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req StoryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	story, err := s.dealer.AddStory(GameID(gameId), currentPlayer(c).ID, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, &story)
+}
+
+// importStories reads stories from a CSV file or a JSON array, depending on Content-Type.
+func (s *Server) importStories(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req ImportStoriesRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+	var parse func(io.Reader) ([]StoryRequest, error)
+	switch c.ContentType() {
+	case "text/csv":
+		parse = func(r io.Reader) ([]StoryRequest, error) { return ParseStoriesCSV(r, req.columns()) }
+	case gin.MIMEJSON, "":
+		parse = ParseStoriesJSON
 	default:
-		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		abortWithError(c, fmt.Errorf("%w %q, stories are imported from text/csv or application/json",
+			ErrUnsupportedMediaType, c.ContentType()))
+		return
+	}
+	// the body is read before parsing, as parsers don't tell a body that is too large from a malformed one
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize+1))
+	if err != nil {
+		abortWithError(c, fmt.Errorf("%w: %s", ErrInvalidImport, err))
+		return
+	}
+	if len(body) > maxImportSize {
+		abortWithError(c, fmt.Errorf("%w: more than %d bytes", ErrImportTooLarge, maxImportSize))
+		return
+	}
+	stories, err := parse(bytes.NewReader(body))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	poker, err := s.dealer.ImportStories(GameID(gameId), currentPlayer(c).ID, stories, req.Mode)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) reorderStories(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req ReorderStoriesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	poker, err := s.dealer.ReorderStories(GameID(gameId), currentPlayer(c).ID, req.StoryIDs)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) removeStory(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	storyId, ok := ParamUint64(c, "storyId")
+	if !ok {
+		abortWithError(c, ErrBadStoryID)
+		return
+	}
+	if err := s.dealer.RemoveStory(GameID(gameId), currentPlayer(c).ID, StoryID(storyId)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) nextStory(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	poker, err := s.dealer.NextStory(GameID(gameId), currentPlayer(c).ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &poker)
+}
+
+func (s *Server) setEstimate(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	storyId, ok := ParamUint64(c, "storyId")
+	if !ok {
+		abortWithError(c, ErrBadStoryID)
+		return
+	}
+	var req EstimateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	story, err := s.dealer.SetEstimate(GameID(gameId), currentPlayer(c).ID, StoryID(storyId), req.Estimate)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &story)
+}
+
+func (s *Server) addWebhook(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	var req WebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	hook, err := s.dealer.AddWebhook(GameID(gameId), currentPlayer(c).ID, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, &hook)
+}
+
+func (s *Server) listWebhooks(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	hooks, err := s.dealer.Webhooks(GameID(gameId), currentPlayer(c).ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &hooks)
+}
+
+func (s *Server) removeWebhook(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	webhookId, ok := ParamUint64(c, "webhookId")
+	if !ok {
+		abortWithError(c, ErrBadWebhookID)
+		return
+	}
+	if err := s.dealer.RemoveWebhook(GameID(gameId), currentPlayer(c).ID, WebhookID(webhookId)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) webhookDeliveries(c *gin.Context) {
+	gameId, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	webhookId, ok := ParamUint64(c, "webhookId")
+	if !ok {
+		abortWithError(c, ErrBadWebhookID)
+		return
+	}
+	deliveries, err := s.dealer.WebhookDeliveries(GameID(gameId), currentPlayer(c).ID, WebhookID(webhookId))
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, &deliveries)
+}
+
+func (s *Server) globalWebhookDeliveries(c *gin.Context) {
+	webhookId, ok := ParamUint64(c, "webhookId")
+	if !ok {
+		abortWithError(c, ErrBadWebhookID)
+		return
+	}
+	deliveries, err := s.dealer.GlobalWebhookDeliveries(WebhookID(webhookId))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &deliveries)
 }
 
 func (s *Server) serveWS(c *gin.Context) {
-	// TODO add context for those logs
-	// TODO this should subscribe to game events, which we don't even have yet :D
-	upgrader := websocket.Upgrader{
-		HandshakeTimeout: 5 * time.Second,
-		ReadBufferSize:   1024,
-		WriteBufferSize:  1024,
-		Subprotocols:     nil,
-		CheckOrigin:      func(r *http.Request) bool { return true }, // TODO this should be fixed later.
+	gameID, ok := ParamUint64(c, "gameId")
+	if !ok {
+		abortWithError(c, ErrBadGameID)
+		return
+	}
+	player, authenticated, err := s.optionalPlayer(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	// Subscribe before upgrading, so we can still answer with a proper HTTP status
+	snapshot, sub, err := s.dealer.Subscribe(GameID(gameID))
 	if err != nil {
-		log.Printf("Failed to upgrade ws connection: %s", err)
+		abortWithError(c, err)
+		return
+	}
+	defer s.dealer.Unsubscribe(sub)
+	snapshot.hideCode(player, authenticated)
+
+	log := withLogFields(c, logrus.Fields{"game_id": gameID})
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil { // the upgrader has already answered with an error
+		log.WithError(err).Info("Failed to upgrade websocket connection")
 		return
 	}
-	log.Printf("Upgraded connection!!!")
 	defer conn.Close()
-	for {
-		mesType, message, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("New Message of type %d: %s", mesType, string(message))
-			break
+	log.Debug("Websocket connected")
+	defer log.Debug("Websocket disconnected")
+	s.metrics.wsConnected(GameID(gameID))
+	defer s.metrics.wsDisconnected(GameID(gameID))
+	// archived games have no presence, their subscription is already closed anyway
+	if authenticated && s.dealer.Connect(GameID(gameID), player.ID) == nil {
+		defer func() {
+			err := s.dealer.Disconnect(GameID(gameID), player.ID)
+			if err != nil && !errors.Is(err, ErrGameNotFound) && !errors.Is(err, ErrGameArchived) {
+				log.WithError(err).Error("Failed to disconnect player")
+			}
+		}()
+	}
+
+	// We don't expect anything from the client, but reading is needed to process control messages and notice when
+	// the connection is closed. A client that stops answering pings is disconnected by the read deadline.
+	pongWait := 2 * s.cfg.WSPingPeriod
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		if authenticated {
+			s.dealer.Seen(GameID(gameID), player.ID)
 		}
-		if err = conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
-			log.Printf("Error while writing to ws: %s", err)
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	ping := time.NewTicker(s.cfg.WSPingPeriod)
+	defer ping.Stop()
+
+	if err = writeEvent(conn, NewEvent(EventGameSnapshot, snapshot.ID, snapshot)); err != nil {
+		log.WithError(err).Info("Failed to write to websocket")
+		return
+	}
+	s.metrics.wsMessageSent()
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok { // subscription ended, most likely the server is stopping
+				_ = conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(wsWriteTimeout),
+				)
+				return
+			}
+			if err = writeEvent(conn, event); err != nil {
+				log.WithError(err).Info("Failed to write to websocket")
+				return
+			}
+			s.metrics.wsMessageSent()
+		case <-ping.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				log.WithError(err).Info("Failed to ping websocket")
+				return
+			}
+		case <-closed:
+			return
 		}
 	}
 }
 
+func writeEvent(conn *websocket.Conn, event Event) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(&event)
+}
+
 // ParamUint64 extracts parameter from gin.Context that is expected to be uint64.
 func ParamUint64(c *gin.Context, name string) (uint64, bool) {
 	idStr := c.Param(name)
 	id, err := strconv.ParseUint(idStr, 10, 0)
 	return id, err == nil
 }
-
-func errGameNotFound(gameId GameID) error {
-	return fmt.Errorf("game with id %d not found", gameId)
-}
@@ -15,7 +15,7 @@ import (
 )
 
 func TestCreate(t *testing.T) {
-	srv := game.NewStartedServer()
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 
@@ -25,48 +25,92 @@ func TestCreate(t *testing.T) {
 func TestCreateGameMissingFieldsError(t *testing.T) {
 	tests := []struct {
 		name string
-		body func(creatorID game.PlayerID) *bytes.Buffer
+		body string
 	}{
 		{
 			name: "no game name",
-			body: func(creatorID game.PlayerID) *bytes.Buffer {
-				return bytes.NewBufferString(fmt.Sprintf(`{"creatorId":%d}`, creatorID))
-			},
+			body: `{}`,
 		},
 		{
-			name: "no creator ID",
-			body: func(game.PlayerID) *bytes.Buffer {
-				return bytes.NewBufferString(`{"gameName": "aaaa"}`)
+			name: "malformed json",
+			body: `{z`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := startServer(t)
+			defer srv.Stop(context.Background())
+			waitForServer(t)
+			resp := doRaw(t, createUser(t).Token, http.MethodPost, "/api/games", test.body)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func TestCreateGameWithDeck(t *testing.T) {
+	tests := []struct {
+		name         string
+		deck         game.DeckType
+		cards        []game.Vote
+		expectedDeck game.Deck
+	}{
+		{
+			name: "default deck",
+			expectedDeck: game.Deck{
+				Type:  game.DeckFibonacci,
+				Cards: []game.Vote{"0", "1", "2", "3", "5", "8", "13", "21", "34", "55", "89", "?", "☕"},
 			},
 		},
 		{
-			name: "no creator ID and no game name",
-			body: func(creatorID game.PlayerID) *bytes.Buffer {
-				return bytes.NewBufferString(`{}`)
+			name: "t-shirt sizes",
+			deck: game.DeckTShirt,
+			expectedDeck: game.Deck{
+				Type:  game.DeckTShirt,
+				Cards: []game.Vote{"XS", "S", "M", "L", "XL", "XXL", "?", "☕"},
 			},
 		},
 		{
-			name: "malformed json",
-			body: func(creatorID game.PlayerID) *bytes.Buffer {
-				return bytes.NewBufferString(`{z`)
+			name:  "custom",
+			deck:  game.DeckCustom,
+			cards: []game.Vote{"1", "2", "?"},
+			expectedDeck: game.Deck{
+				Type:  game.DeckCustom,
+				Cards: []game.Vote{"1", "2", "?"},
 			},
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			srv := game.NewStartedServer()
+			srv := startServer(t)
 			defer srv.Stop(context.Background())
 			waitForServer(t)
-			buffer := test.body(createUser(t).ID)
-			resp, err := http.Post(fullPath("/api/games"), "application/json", buffer)
-			require.NoError(t, err)
-			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			gameID := createGame(t, createUser(t).Token, game.CreatePokerRequest{
+				GameName: gen.RandLowercaseString(),
+				Deck:     test.deck,
+				Cards:    test.cards,
+			})
+			require.Equal(t, test.expectedDeck, getGame(t, gameID).Deck)
 		})
 	}
 }
 
+func TestCreateGameInvalidDeck(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	for _, body := range []string{
+		`{"gameName":"a","deck":"tarot"}`,
+		`{"gameName":"a","deck":"custom"}`,
+		`{"gameName":"a","deck":"fibonacci","cards":["1"]}`,
+	} {
+		resp := doRaw(t, creator.Token, http.MethodPost, "/api/games", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+}
+
 func TestCreateAndGetAGame(t *testing.T) {
-	srv := game.NewStartedServer()
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 	pokerID := createDefaultGame(t, createUser(t))
@@ -96,7 +140,7 @@ func TestGetGameErrors(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			srv := game.NewStartedServer()
+			srv := startServer(t)
 			defer srv.Stop(context.Background())
 			waitForServer(t)
 			// use string here because we want to test that only ints are accepted
@@ -110,49 +154,49 @@ func TestGetGameErrors(t *testing.T) {
 func TestListGames(t *testing.T) {
 	tests := []struct {
 		name                   string
-		generateCreatorsIDs    func(t *testing.T) []game.PlayerID
+		generateCreators       func(t *testing.T) []game.SignupResponse
 		numberOfGamesPerPlayer int
 	}{
 		{
 			name:                   "zero games",
-			generateCreatorsIDs:    func(t *testing.T) []game.PlayerID { return nil },
+			generateCreators:       func(t *testing.T) []game.SignupResponse { return nil },
 			numberOfGamesPerPlayer: 0,
 		},
 		{
 			name: "1 game",
-			generateCreatorsIDs: func(t *testing.T) []game.PlayerID {
-				return []game.PlayerID{createUser(t).ID}
+			generateCreators: func(t *testing.T) []game.SignupResponse {
+				return []game.SignupResponse{createUser(t)}
 			},
 			numberOfGamesPerPlayer: 1,
 		},
 		{
 			name: "2 games 2 creators",
-			generateCreatorsIDs: func(t *testing.T) []game.PlayerID {
-				return []game.PlayerID{createUser(t).ID, createUser(t).ID}
+			generateCreators: func(t *testing.T) []game.SignupResponse {
+				return []game.SignupResponse{createUser(t), createUser(t)}
 			},
 			numberOfGamesPerPlayer: 1,
 		},
 		{
 			name: "2 games 1 creator",
-			generateCreatorsIDs: func(t *testing.T) []game.PlayerID {
-				return []game.PlayerID{createUser(t).ID}
+			generateCreators: func(t *testing.T) []game.SignupResponse {
+				return []game.SignupResponse{createUser(t)}
 			},
 			numberOfGamesPerPlayer: 2,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			server := game.NewStartedServer()
+			server := startServer(t)
 			defer server.Stop(context.Background())
+			waitForServer(t)
 
 			expectedGames := make([]game.GameListEntry, 0, test.numberOfGamesPerPlayer)
-			for _, creatorID := range test.generateCreatorsIDs(t) {
+			for _, creator := range test.generateCreators(t) {
 				for i := 0; i < test.numberOfGamesPerPlayer; i++ {
 					req := game.CreatePokerRequest{
-						GameName:  gen.RandLowercaseString(),
-						CreatorID: creatorID,
+						GameName: gen.RandLowercaseString(),
 					}
-					gameID := createGame(t, req)
+					gameID := createGame(t, creator.Token, req)
 					expectedGames = append(expectedGames, game.GameListEntry{
 						ID:   gameID,
 						Name: req.GameName,
@@ -163,61 +207,116 @@ func TestListGames(t *testing.T) {
 			resp, err := http.Get(fullPath("/api/games"))
 			require.NoError(t, err)
 			require.Equal(t, http.StatusOK, resp.StatusCode)
-			var resGameNames []game.GameListEntry
-			require.NoError(t, json.NewDecoder(resp.Body).Decode(&resGameNames))
-			require.Equal(t, len(expectedGames), len(resGameNames))
+			var list game.GameListResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+			require.Equal(t, len(expectedGames), len(list.Games))
+			require.Empty(t, list.NextCursor)
 
+			resGameNames := make([]game.GameListEntry, 0, len(list.Games))
+			for _, entry := range list.Games {
+				require.Equal(t, 1, entry.PlayerCount)
+				require.Equal(t, game.RoundVoting, entry.State)
+				resGameNames = append(resGameNames, game.GameListEntry{ID: entry.ID, Name: entry.Name})
+			}
 			require.ElementsMatch(t, expectedGames, resGameNames)
 		})
 	}
 }
 
-func TestJoinNoPlayerID(t *testing.T) {
-	srv := game.NewStartedServer()
+func TestUnauthenticated(t *testing.T) {
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 	creator := createUser(t)
 	gameID := createDefaultGame(t, creator)
-	var buf bytes.Buffer
-	buf.WriteString(`{}`)
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(fullPath("/api/games/%d/join"), gameID), &buf)
-	require.NoError(t, err)
-	resp, err := http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{name: "create game", method: http.MethodPost, path: "/api/games"},
+		{name: "join", method: http.MethodPut, path: fmt.Sprintf("/api/games/%d/join", gameID)},
+		{name: "vote", method: http.MethodPost, path: fmt.Sprintf("/api/games/%d/vote", gameID)},
+		{name: "reveal", method: http.MethodPost, path: fmt.Sprintf("/api/games/%d/reveal", gameID)},
+		{name: "new round", method: http.MethodPost, path: fmt.Sprintf("/api/games/%d/round", gameID)},
+		{name: "add story", method: http.MethodPost, path: fmt.Sprintf("/api/games/%d/stories", gameID)},
+		{name: "logout", method: http.MethodPost, path: "/api/logout"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, token := range []string{"", "garbage", "Zm9v.YmFy", creator.Token + "x"} {
+				resp := doJSON(t, token, test.method, test.path, nil)
+				require.Equal(t, http.StatusUnauthorized, resp.StatusCode, "token %q", token)
+			}
+		})
+	}
 }
 
-func TestJoinNotRegistered(t *testing.T) {
-	srv := game.NewStartedServer()
+func TestLogout(t *testing.T) {
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 	creator := createUser(t)
 	gameID := createDefaultGame(t, creator)
-	joinExpect(t, 100, gameID, http.StatusBadRequest)
+
+	resp := doJSON(t, creator.Token, http.MethodPost, "/api/logout", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	voteExpect(t, creator.Token, "1", gameID, http.StatusUnauthorized)
+	resp = doJSON(t, creator.Token, http.MethodPost, "/api/logout", nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSessionExpires(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.SessionTTL = 100 * time.Millisecond
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	require.WithinDuration(t, time.Now().Add(cfg.SessionTTL), creator.ExpiresAt, time.Second)
+	gameID := createDefaultGame(t, creator)
+
+	time.Sleep(cfg.SessionTTL)
+	voteExpect(t, creator.Token, "1", gameID, http.StatusUnauthorized)
+}
+
+func TestSessionsOfDifferentServersAreNotShared(t *testing.T) {
+	srv := startServer(t)
+	waitForServer(t)
+	creator := createUser(t)
+	require.NoError(t, srv.Stop(context.Background()))
+
+	srv = startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	resp := doJSON(t, creator.Token, http.MethodPost, "/api/games", game.CreatePokerRequest{GameName: "a"})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 }
 
 func TestJoinGame(t *testing.T) {
-	srv := game.NewStartedServer()
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 	creator := createUser(t)
 	gameID := createDefaultGame(t, creator)
-	joiners := []game.Player{
+	joiners := []game.SignupResponse{
 		createUser(t),
 		createUser(t),
 	}
 	for _, player := range joiners {
-		join(t, player.ID, gameID)
+		join(t, player, gameID)
 	}
 	players := make([]game.PlayerResponse, 0, 1+len(joiners))
 	players = append(players, game.PlayerResponse{
 		ID:   creator.ID,
 		Name: creator.Name,
+		Role: game.RoleFacilitator,
 	})
 	for _, joinee := range joiners {
 		players = append(players, game.PlayerResponse{
 			ID:   joinee.ID,
 			Name: joinee.Name,
+			Role: game.RoleVoter,
 		})
 	}
 
@@ -230,43 +329,115 @@ func TestJoinGame(t *testing.T) {
 }
 
 func TestVote(t *testing.T) {
-	srv := game.NewStartedServer()
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
 	creator := createUser(t)
 	gameID := createDefaultGame(t, creator)
-	players := []game.Player{
+	players := []game.SignupResponse{
 		createUser(t),
 		createUser(t),
 	}
 	for _, player := range players {
-		join(t, player.ID, gameID)
+		join(t, player, gameID)
 	}
 	expectedPlayers := make([]game.PlayerResponse, 0, 1+len(players))
 	expectedPlayers = append(expectedPlayers, game.PlayerResponse{
-		ID:   creator.ID,
-		Name: creator.Name,
-		Vote: game.Vote(gen.RandLowercaseString()),
+		ID:    creator.ID,
+		Name:  creator.Name,
+		Role:  game.RoleFacilitator,
+		Voted: true,
+		Vote:  "3",
 	})
-	for _, joining := range players {
+	joinersVotes := []game.Vote{"8", game.CardUnsure}
+	for i, joining := range players {
 		expectedPlayers = append(expectedPlayers, game.PlayerResponse{
-			ID:   joining.ID,
-			Name: joining.Name,
-			Vote: game.Vote(gen.RandLowercaseString()),
+			ID:    joining.ID,
+			Name:  joining.Name,
+			Role:  game.RoleVoter,
+			Voted: true,
+			Vote:  joinersVotes[i],
 		})
 	}
 
 	// Vote
-	for _, player := range expectedPlayers {
-		vote(t, player, gameID)
+	vote(t, creator, "3", gameID)
+	for i, player := range players {
+		vote(t, player, joinersVotes[i], gameID)
 	}
 
-	resp, err := http.Get(fmt.Sprintf(fullPath("/api/games/%d"), gameID))
-	require.NoError(t, err)
-	var poker game.GameResponse
-	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	// Votes are hidden until reveal
+	poker := getGame(t, gameID)
+	require.Equal(t, game.RoundVoting, poker.State)
 	require.Equal(t, len(expectedPlayers), len(poker.Players))
+	for _, player := range poker.Players {
+		require.True(t, player.Voted)
+		require.Empty(t, player.Vote)
+	}
+	require.Nil(t, poker.Stats)
+
+	poker = reveal(t, creator.Token, gameID)
+	require.Equal(t, game.RoundRevealed, poker.State)
 	require.ElementsMatch(t, expectedPlayers, poker.Players)
+	require.NotNil(t, poker.Stats)
+	require.Equal(t, 3, poker.Stats.Votes)
+	require.Equal(t, 5.5, *poker.Stats.Average)
+	require.Equal(t, []game.PlayerID{creator.ID}, poker.Stats.Lowest)
+	require.Equal(t, []game.PlayerID{players[0].ID}, poker.Stats.Highest)
+	require.Equal(t, poker, getGameAs(t, creator.Token, gameID))
+}
+
+func TestVoteNotInDeck(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	for _, v := range []game.Vote{"4", "8 ", "banana", ""} {
+		voteExpect(t, creator.Token, v, gameID, http.StatusBadRequest)
+	}
+	require.False(t, getGame(t, gameID).Players[0].Voted)
+}
+
+func TestVoteAfterReveal(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	reveal(t, creator.Token, gameID)
+	voteExpect(t, creator.Token, "1", gameID, http.StatusConflict)
+}
+
+func TestNewRound(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	vote(t, creator, "1", gameID)
+	reveal(t, creator.Token, gameID)
+
+	resp := doJSON(t, creator.Token, http.MethodPost, fmt.Sprintf("/api/games/%d/round", gameID), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	require.Equal(t, game.RoundVoting, poker.State)
+	require.Equal(t, []game.PlayerResponse{{ID: creator.ID, Name: creator.Name, Role: game.RoleFacilitator}}, poker.Players)
+
+	// can vote again
+	vote(t, creator, "2", gameID)
+}
+
+func TestRoundGameNotFound(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	player := createUser(t)
+	for _, path := range []string{"/api/games/100/reveal", "/api/games/100/round"} {
+		resp := doJSON(t, player.Token, http.MethodPost, path, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
 }
 
 func TestSignupErrors(t *testing.T) {
@@ -283,7 +454,7 @@ func TestSignupErrors(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			srv := game.NewStartedServer()
+			srv := startServer(t)
 			defer srv.Stop(context.Background())
 			waitForServer(t)
 
@@ -300,79 +471,211 @@ func TestSignupErrors(t *testing.T) {
 }
 
 func TestWebsocketNotifications(t *testing.T) {
-	srv := game.NewStartedServer()
+	srv := startServer(t)
 	defer srv.Stop(context.Background())
 	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
 
-	conn, _, err := websocket.DefaultDialer.Dial("ws://localhost:8080/ws/games/1", http.Header{})
-	require.NoError(t, err)
-	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hi")))
-	mType, message, err := conn.ReadMessage()
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:8080/ws/games/%d", gameID), http.Header{})
 	require.NoError(t, err)
-	require.Equal(t, websocket.TextMessage, mType)
-	require.Equal(t, "hello", string(message))
+	defer conn.Close()
+
+	snapshot := readEvent(t, conn)
+	require.Equal(t, game.EventGameSnapshot, snapshot.Type)
+	require.Equal(t, gameID, snapshot.GameID)
+	var poker game.GameResponse
+	require.NoError(t, json.Unmarshal(snapshot.Payload, &poker))
+	require.Equal(t, gameID, poker.ID)
+	require.Equal(t, []game.PlayerResponse{{ID: creator.ID, Name: creator.Name, Role: game.RoleFacilitator}}, poker.Players)
+
+	joining := createUser(t)
+	join(t, joining, gameID)
+	joined := readEvent(t, conn)
+	require.Equal(t, game.EventPlayerJoined, joined.Type)
+	var player game.PlayerResponse
+	require.NoError(t, json.Unmarshal(joined.Payload, &player))
+	require.Equal(t, game.PlayerResponse{ID: joining.ID, Name: joining.Name, Role: game.RoleVoter}, player)
+
+	vote(t, joining, "5", gameID)
+	voted := readEvent(t, conn)
+	require.Equal(t, game.EventVoteCast, voted.Type)
+	var voteCast game.VoteCast
+	require.NoError(t, json.Unmarshal(voted.Payload, &voteCast))
+	require.Equal(t, game.VoteCast{PlayerID: joining.ID}, voteCast)
+
+	reveal(t, creator.Token, gameID)
+	revealed := readEvent(t, conn)
+	require.Equal(t, game.EventRoundRevealed, revealed.Type)
+	require.NoError(t, json.Unmarshal(revealed.Payload, &poker))
+	require.Equal(t, game.RoundRevealed, poker.State)
+	require.ElementsMatch(t, []game.PlayerResponse{
+		{ID: creator.ID, Name: creator.Name, Role: game.RoleFacilitator},
+		{ID: joining.ID, Name: joining.Name, Role: game.RoleVoter, Voted: true, Vote: "5"},
+	}, poker.Players)
 }
 
-func join(t *testing.T, playedID game.PlayerID, gameID game.GameID) {
-	joinExpect(t, playedID, gameID, http.StatusOK)
+func TestWebsocketGameNotFound(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws://localhost:8080/ws/games/100", http.Header{})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
-func joinExpect(t *testing.T, playedID game.PlayerID, gameID game.GameID, expectedResponseCode int) {
-	var buf bytes.Buffer
-	body := game.JoinPokerRequest{PlayerID: playedID}
-	require.NoError(t, json.NewEncoder(&buf).Encode(&body))
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(fullPath("/api/games/%d/join"), gameID), &buf)
+func TestWebsocketClosedOnStop(t *testing.T) {
+	srv := startServer(t)
+	waitForServer(t)
+	gameID := createDefaultGame(t, createUser(t))
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:8080/ws/games/%d", gameID), http.Header{})
 	require.NoError(t, err)
-	resp, err := http.DefaultClient.Do(req)
+	defer conn.Close()
+	readEvent(t, conn) // snapshot
+
+	require.NoError(t, srv.Stop(context.Background()))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %s", err)
+}
+
+func TestWebsocketOriginCheck(t *testing.T) {
+	cfg := game.DefaultConfig()
+	cfg.CORSOrigins = []string{"http://poker.example.com"}
+	cfg.WSCheckOrigin = true
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	gameID := createDefaultGame(t, createUser(t))
+	url := fmt.Sprintf("ws://localhost:8080/ws/games/%d", gameID)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://poker.example.com"}})
 	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://evil.example.com"}})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) game.Event {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	var event game.Event
+	require.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+func join(t *testing.T, player game.SignupResponse, gameID game.GameID) {
+	joinExpect(t, player.Token, gameID, http.StatusOK)
+}
+
+func joinExpect(t *testing.T, token string, gameID game.GameID, expectedResponseCode int) {
+	resp := doJSON(t, token, http.MethodPut, fmt.Sprintf("/api/games/%d/join", gameID), nil)
 	require.Equal(t, expectedResponseCode, resp.StatusCode)
 }
 
-func vote(t *testing.T, player game.PlayerResponse, gameID game.GameID) {
-	var buf bytes.Buffer
-	request := game.VoteRequest{
-		PlayerID: player.ID,
-		Vote:     player.Vote,
-	}
-	require.NoError(t, json.NewEncoder(&buf).Encode(&request))
-	resp, err := http.Post(fmt.Sprintf(fullPath("/api/games/%d/vote"), gameID), "application/json", &buf)
+func vote(t *testing.T, player game.SignupResponse, vote game.Vote, gameID game.GameID) {
+	voteExpect(t, player.Token, vote, gameID, http.StatusOK)
+}
+
+func voteExpect(t *testing.T, token string, vote game.Vote, gameID game.GameID, expectedResponseCode int) {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/vote", gameID), game.VoteRequest{Vote: vote})
+	require.Equal(t, expectedResponseCode, resp.StatusCode)
+}
+
+func reveal(t *testing.T, token string, gameID game.GameID) game.GameResponse {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/reveal", gameID), nil)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	return poker
+}
+
+func getGame(t *testing.T, gameID game.GameID) game.GameResponse {
+	resp, err := http.Get(fmt.Sprintf(fullPath("/api/games/%d"), gameID))
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	return poker
+}
+
+// getGameAs gets a game with a session, so the join code is there if the player is in the game.
+func getGameAs(t *testing.T, token string, gameID game.GameID) game.GameResponse {
+	resp := doJSON(t, token, http.MethodGet, fmt.Sprintf("/api/games/%d", gameID), nil)
+	defer resp.Body.Close()
 	require.Equal(t, http.StatusOK, resp.StatusCode)
-	buf.Reset()
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	return poker
 }
 
-func createUser(t *testing.T) game.Player {
+// createUser signs up a player with a random name and returns it with its session token.
+func createUser(t *testing.T) game.SignupResponse {
 	req := game.RegisterUserRequest{Name: gen.RandLowercaseString()}
-	var buffer bytes.Buffer
-	require.NoError(t, json.NewEncoder(&buffer).Encode(&req))
-	resp, err := http.Post(fullPath("/api/signup"), "application/json", &buffer)
-	require.NoError(t, err)
+	resp := doJSON(t, "", http.MethodPost, "/api/signup", req)
 	defer resp.Body.Close()
-	var player game.Player
-	require.NoError(t, json.NewDecoder(resp.Body).Decode(&player))
-	require.NotZero(t, player)
-	require.Equal(t, req.Name, player.Name)
-	return player
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var signup game.SignupResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&signup))
+	require.NotZero(t, signup.Player)
+	require.NotEmpty(t, signup.Token)
+	require.Equal(t, req.Name, signup.Name)
+	return signup
 }
 
-func createGame(t *testing.T, req game.CreatePokerRequest) game.GameID {
-	var buffer bytes.Buffer
-	require.NoError(t, json.NewEncoder(&buffer).Encode(&req))
-	resp, err := http.Post(fullPath("/api/games"), "application/json", &buffer)
-	require.NoError(t, err)
+func createGame(t *testing.T, token string, req game.CreatePokerRequest) game.GameID {
+	resp := doJSON(t, token, http.MethodPost, "/api/games", req)
+	defer resp.Body.Close()
 	require.Equal(t, http.StatusCreated, resp.StatusCode)
 	var poker game.GameResponse
-	err = json.NewDecoder(resp.Body).Decode(&poker) //not entirely correct...
+	err := json.NewDecoder(resp.Body).Decode(&poker) //not entirely correct...
 	require.NoError(t, err)
 	return poker.ID
 }
 
-func createDefaultGame(t *testing.T, player game.Player) game.GameID {
+func createDefaultGame(t *testing.T, player game.SignupResponse) game.GameID {
 	var createGameReq = game.CreatePokerRequest{
-		GameName:  gen.RandLowercaseString(),
-		CreatorID: player.ID,
+		GameName: gen.RandLowercaseString(),
+	}
+	return createGame(t, player.Token, createGameReq)
+}
+
+// doJSON sends body encoded as JSON, authenticated with token unless it's empty.
+func doJSON(t *testing.T, token, method, path string, body any) *http.Response {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	return doRaw(t, token, method, path, buf.String())
+}
+
+// doRaw sends body as is, authenticated with token unless it's empty.
+func doRaw(t *testing.T, token, method, path, body string) *http.Response {
+	req, err := http.NewRequest(method, fullPath(path), bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
-	return createGame(t, createGameReq)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func startServer(t *testing.T) *game.Server {
+	return startServerWithConfig(t, game.DefaultConfig())
+}
+
+func startServerWithConfig(t *testing.T, cfg game.Config) *game.Server {
+	srv, err := game.NewStartedServer(cfg)
+	require.NoError(t, err)
+	return srv
 }
 
 func waitForServer(t *testing.T) {
@@ -0,0 +1,70 @@
+package game
+
+// GameSettings change how a game is run. The zero value is the default.
+type GameSettings struct {
+	AutoReveal    bool `json:"autoReveal"`    // reveal the round once every voter has voted
+	IgnoreOffline bool `json:"ignoreOffline"` // don't wait for votes of players who are not connected
+}
+
+// SettingsChanged is a payload of EventSettingsChanged.
+type SettingsChanged struct {
+	Settings GameSettings `json:"settings"`
+}
+
+// UpdateSettings changes the settings of a game that are set in req, the others are kept. Only the facilitator can
+// change settings. If auto-reveal is turned on when everyone has voted already, the round is revealed right away.
+func (d *Dealer) UpdateSettings(gameID GameID, playerID PlayerID, req GameSettingsRequest) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	if req.AutoReveal != nil {
+		game.Settings.AutoReveal = *req.AutoReveal
+	}
+	if req.IgnoreOffline != nil {
+		game.Settings.IgnoreOffline = *req.IgnoreOffline
+	}
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.hub.Publish(NewEvent(EventSettingsChanged, gameID, SettingsChanged{Settings: game.Settings}))
+	d.autoReveal(game)
+	return gameToResponse(game), nil
+}
+
+// autoReveal reveals the round if the game is set to do so and every voter has voted. It's checked after every change
+// of votes or players, once the change is saved. A failure to reveal is only logged: the change itself succeeded, and
+// the round stays open, so the next change or the facilitator reveals it. The game must be locked.
+func (d *Dealer) autoReveal(game *Poker) {
+	if !game.Settings.AutoReveal || game.State != RoundVoting || !everyoneVoted(game) {
+		return
+	}
+	if _, err := d.revealRound(game); err != nil {
+		d.log.WithField("game_id", game.ID).WithError(err).Error("Failed to reveal round automatically")
+		return
+	}
+	d.log.WithField("game_id", game.ID).Info("Round revealed automatically")
+}
+
+// everyoneVoted tells whether every player who can vote has voted. Offline players are not waited for if the game
+// ignores them. A round without votes is never done.
+func everyoneVoted(game *Poker) bool {
+	votes := 0
+	for id, role := range game.Roles {
+		if role == RoleObserver {
+			continue
+		}
+		if _, ok := game.Votes[id]; ok {
+			votes++
+			continue
+		}
+		if !game.Settings.IgnoreOffline || online(game, id) {
+			return false
+		}
+	}
+	return votes > 0
+}
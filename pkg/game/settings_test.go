@@ -0,0 +1,72 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+)
+
+func TestAutoReveal(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	observer := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	joinAs(t, observer.Token, gameID, game.RoleObserver, http.StatusOK)
+
+	require.False(t, getGame(t, gameID).Settings.AutoReveal)
+	resp := updateSettings(t, voter.Token, gameID, true)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = updateSettings(t, creator.Token, gameID, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	require.True(t, poker.Settings.AutoReveal)
+
+	conn := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+	vote(t, creator, "5", gameID)
+	require.Equal(t, game.RoundVoting, getGame(t, gameID).State)
+	vote(t, voter, "8", gameID)
+	expectEvents(t, conn, game.EventVoteCast, game.EventVoteCast, game.EventRoundRevealed)
+	require.Equal(t, game.RoundRevealed, getGame(t, gameID).State)
+
+	// a player who joins mid-round is waited for, one who leaves is not
+	newRound(t, creator.Token, gameID)
+	vote(t, creator, "5", gameID)
+	latecomer := createUser(t)
+	join(t, latecomer, gameID)
+	vote(t, voter, "8", gameID)
+	require.Equal(t, game.RoundVoting, getGame(t, gameID).State)
+	resp = doJSON(t, latecomer.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/join", gameID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, game.RoundRevealed, getGame(t, gameID).State)
+
+	// so is a voter who becomes an observer
+	newRound(t, creator.Token, gameID)
+	vote(t, creator, "5", gameID)
+	joinAs(t, voter.Token, gameID, game.RoleObserver, http.StatusOK)
+	poker = getGame(t, gameID)
+	require.Equal(t, game.RoundRevealed, poker.State)
+	require.Equal(t, 1, poker.Stats.Votes)
+
+	// turning it on when everyone has voted reveals right away
+	newRound(t, creator.Token, gameID)
+	require.Equal(t, http.StatusOK, updateSettings(t, creator.Token, gameID, false).StatusCode)
+	vote(t, creator, "3", gameID)
+	require.Equal(t, game.RoundVoting, getGame(t, gameID).State)
+	require.Equal(t, http.StatusOK, updateSettings(t, creator.Token, gameID, true).StatusCode)
+	require.Equal(t, game.RoundRevealed, getGame(t, gameID).State)
+}
+
+func updateSettings(t *testing.T, token string, gameID game.GameID, autoReveal bool) *http.Response {
+	path := fmt.Sprintf("/api/games/%d/settings", gameID)
+	return doJSON(t, token, http.MethodPatch, path, game.GameSettingsRequest{AutoReveal: &autoReveal})
+}
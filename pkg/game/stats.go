@@ -0,0 +1,111 @@
+package game
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// RoundStats summarizes votes of a round. Average, Median and outliers are calculated only from numeric cards, while
+// non-numeric ones like CardUnsure are still counted in Distribution and Mode.
+type RoundStats struct {
+	Votes        int         `json:"votes"` // number of players who voted
+	Average      *float64    `json:"average,omitempty"`
+	Median       *float64    `json:"median,omitempty"`
+	Mode         []Vote      `json:"mode"`         // the most frequent cards in deck order
+	Distribution []CardCount `json:"distribution"` // every card of the deck in deck order
+	Consensus    bool        `json:"consensus"`    // everyone voted for the same estimate
+	Lowest       []PlayerID  `json:"lowest"`       // players with the lowest numeric vote, if not everyone agreed
+	Highest      []PlayerID  `json:"highest"`      // players with the highest numeric vote, if not everyone agreed
+}
+
+// CardCount is a number of votes for a card.
+type CardCount struct {
+	Card  Vote `json:"card"`
+	Count int  `json:"count"`
+}
+
+// cardValues are numeric values of cards that can't be parsed as numbers.
+var cardValues = map[Vote]float64{
+	"½": 0.5,
+}
+
+// ComputeStats calculates statistics of votes cast with cards from deck.
+func ComputeStats(deck Deck, votes map[PlayerID]Vote) RoundStats {
+	stats := RoundStats{
+		Votes:        len(votes),
+		Mode:         []Vote{},
+		Distribution: make([]CardCount, 0, len(deck.Cards)),
+		Lowest:       []PlayerID{},
+		Highest:      []PlayerID{},
+	}
+	counts := make(map[Vote]int, len(deck.Cards))
+	numeric := make([]float64, 0, len(votes))
+	values := make(map[PlayerID]float64, len(votes))
+	for playerID, vote := range votes {
+		counts[vote]++
+		if value, ok := CardValue(vote); ok {
+			numeric = append(numeric, value)
+			values[playerID] = value
+		}
+	}
+
+	maxCount := 0
+	for _, card := range deck.Cards {
+		stats.Distribution = append(stats.Distribution, CardCount{Card: card, Count: counts[card]})
+		if counts[card] > maxCount {
+			maxCount = counts[card]
+		}
+	}
+	for _, card := range deck.Cards {
+		if maxCount > 0 && counts[card] == maxCount {
+			stats.Mode = append(stats.Mode, card)
+		}
+	}
+	if len(counts) == 1 {
+		for card := range counts {
+			stats.Consensus = card != CardUnsure && card != CardCoffee
+		}
+	}
+
+	if len(numeric) == 0 {
+		return stats
+	}
+	sort.Float64s(numeric)
+	sum := 0.0
+	for _, value := range numeric {
+		sum += value
+	}
+	average := sum / float64(len(numeric))
+	median := numeric[len(numeric)/2]
+	if len(numeric)%2 == 0 {
+		median = (numeric[len(numeric)/2-1] + median) / 2
+	}
+	stats.Average = &average
+	stats.Median = &median
+
+	lowest, highest := numeric[0], numeric[len(numeric)-1]
+	if lowest == highest {
+		return stats
+	}
+	for playerID, value := range values {
+		switch value {
+		case lowest:
+			stats.Lowest = append(stats.Lowest, playerID)
+		case highest:
+			stats.Highest = append(stats.Highest, playerID)
+		}
+	}
+	sort.Slice(stats.Lowest, func(i, j int) bool { return stats.Lowest[i] < stats.Lowest[j] })
+	sort.Slice(stats.Highest, func(i, j int) bool { return stats.Highest[i] < stats.Highest[j] })
+	return stats
+}
+
+// CardValue returns a numeric value of a card if it has one.
+func CardValue(card Vote) (float64, bool) {
+	if value, ok := cardValues[card]; ok {
+		return value, true
+	}
+	value, err := strconv.ParseFloat(string(card), 64)
+	return value, err == nil && !math.IsNaN(value) && !math.IsInf(value, 0)
+}
@@ -0,0 +1,137 @@
+package game_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	fibonacci, err := game.NewDeck(game.DeckFibonacci, nil)
+	require.NoError(t, err)
+	tshirt, err := game.NewDeck(game.DeckTShirt, nil)
+	require.NoError(t, err)
+	modified, err := game.NewDeck(game.DeckModifiedFibonacci, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		deck      game.Deck
+		votes     map[game.PlayerID]game.Vote
+		average   *float64
+		median    *float64
+		mode      []game.Vote
+		consensus bool
+		lowest    []game.PlayerID
+		highest   []game.PlayerID
+	}{
+		{
+			name:    "no votes",
+			deck:    fibonacci,
+			votes:   map[game.PlayerID]game.Vote{},
+			mode:    []game.Vote{},
+			lowest:  []game.PlayerID{},
+			highest: []game.PlayerID{},
+		},
+		{
+			name:      "consensus",
+			deck:      fibonacci,
+			votes:     map[game.PlayerID]game.Vote{1: "5", 2: "5"},
+			average:   float(5),
+			median:    float(5),
+			mode:      []game.Vote{"5"},
+			consensus: true,
+			lowest:    []game.PlayerID{},
+			highest:   []game.PlayerID{},
+		},
+		{
+			name:    "outliers",
+			deck:    fibonacci,
+			votes:   map[game.PlayerID]game.Vote{1: "1", 2: "3", 3: "3", 4: "13", 5: "1"},
+			average: float(4.2),
+			median:  float(3),
+			mode:    []game.Vote{"1", "3"},
+			lowest:  []game.PlayerID{1, 5},
+			highest: []game.PlayerID{4},
+		},
+		{
+			name:    "even number of votes",
+			deck:    modified,
+			votes:   map[game.PlayerID]game.Vote{1: "½", 2: "2", 3: "3", 4: "100"},
+			average: float(26.375),
+			median:  float(2.5),
+			mode:    []game.Vote{"½", "2", "3", "100"},
+			lowest:  []game.PlayerID{1},
+			highest: []game.PlayerID{4},
+		},
+		{
+			name:    "non-numeric cards are excluded from numeric stats",
+			deck:    fibonacci,
+			votes:   map[game.PlayerID]game.Vote{1: "2", 2: game.CardUnsure, 3: game.CardUnsure, 4: "8"},
+			average: float(5),
+			median:  float(5),
+			mode:    []game.Vote{game.CardUnsure},
+			lowest:  []game.PlayerID{1},
+			highest: []game.PlayerID{4},
+		},
+		{
+			name:    "everyone is unsure",
+			deck:    fibonacci,
+			votes:   map[game.PlayerID]game.Vote{1: game.CardUnsure, 2: game.CardUnsure},
+			mode:    []game.Vote{game.CardUnsure},
+			lowest:  []game.PlayerID{},
+			highest: []game.PlayerID{},
+		},
+		{
+			name:      "no numeric cards in deck",
+			deck:      tshirt,
+			votes:     map[game.PlayerID]game.Vote{1: "M", 2: "M"},
+			mode:      []game.Vote{"M"},
+			consensus: true,
+			lowest:    []game.PlayerID{},
+			highest:   []game.PlayerID{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stats := game.ComputeStats(test.deck, test.votes)
+			require.Equal(t, len(test.votes), stats.Votes)
+			if test.average == nil {
+				require.Nil(t, stats.Average)
+				require.Nil(t, stats.Median)
+			} else {
+				require.InDelta(t, *test.average, *stats.Average, 1e-9)
+				require.InDelta(t, *test.median, *stats.Median, 1e-9)
+			}
+			require.Equal(t, test.mode, stats.Mode)
+			require.Equal(t, test.consensus, stats.Consensus)
+			require.Equal(t, test.lowest, stats.Lowest)
+			require.Equal(t, test.highest, stats.Highest)
+
+			require.Len(t, stats.Distribution, len(test.deck.Cards))
+			total := 0
+			for i, count := range stats.Distribution {
+				require.Equal(t, test.deck.Cards[i], count.Card)
+				total += count.Count
+			}
+			require.Equal(t, len(test.votes), total)
+		})
+	}
+}
+
+func TestCardValue(t *testing.T) {
+	value, ok := game.CardValue("13")
+	require.True(t, ok)
+	require.Equal(t, 13.0, value)
+	value, ok = game.CardValue("½")
+	require.True(t, ok)
+	require.Equal(t, 0.5, value)
+	for _, card := range []game.Vote{game.CardUnsure, game.CardCoffee, "XL", "NaN", "Inf"} {
+		_, ok = game.CardValue(card)
+		require.False(t, ok, card)
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,96 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnknownStorage = errors.New("unknown storage backend")
+
+// GameStore persists games. Dealer keeps all games in memory and saves every change of a game to the store, so the
+// store is only read when Dealer is created.
+type GameStore interface {
+	// LoadGames returns all saved games and the ID that the next created game should get.
+	LoadGames() (games []*Poker, nextGameID GameID, err error)
+	SaveGame(poker *Poker) error
+	DeleteGame(id GameID) error
+	SaveNextGameID(id GameID) error
+}
+
+// PlayerStore persists players the same way GameStore persists games.
+type PlayerStore interface {
+	// LoadPlayers returns all saved players and the ID that the next registered player should get.
+	LoadPlayers() (players []Player, nextPlayerID PlayerID, err error)
+	SavePlayer(player Player) error
+	SaveNextPlayerID(id PlayerID) error
+}
+
+// SessionStore persists sessions the same way GameStore persists games, so players stay signed in after a restart.
+type SessionStore interface {
+	// LoadSessions returns all saved sessions. Expired ones may be among them.
+	LoadSessions() ([]SavedSession, error)
+	SaveSession(session SavedSession) error
+	DeleteSession(id string) error
+}
+
+// SavedSession is a session as it's kept in a SessionStore. Only a hash of the session ID is saved, so tokens can't be
+// recovered from the store.
+type SavedSession struct {
+	ID        string    `json:"id"` // hex SHA-256 of the session ID
+	PlayerID  PlayerID  `json:"playerId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Store keeps games, players and sessions.
+type Store interface {
+	GameStore
+	PlayerStore
+	SessionStore
+	Close() error
+}
+
+// StorageBackend names a Store implementation.
+type StorageBackend string
+
+const (
+	StorageMemory StorageBackend = "memory"
+	StorageFile   StorageBackend = "file"
+)
+
+// StorageConfig selects where games and players are kept.
+type StorageConfig struct {
+	Backend StorageBackend `json:"backend" yaml:"backend"` // StorageMemory if empty
+	Path    string         `json:"path" yaml:"path"`       // file used by StorageFile
+}
+
+// OpenStore creates a Store described by cfg.
+func OpenStore(cfg StorageConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", StorageMemory:
+		return MemoryStore{}, nil
+	case StorageFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("path is required for %q storage", StorageFile)
+		}
+		return OpenFileStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownStorage, cfg.Backend)
+	}
+}
+
+// MemoryStore doesn't save anything. Games, players and sessions live only in memory of Dealer, PlayerRegistry and
+// Sessions and are lost when the server stops.
+type MemoryStore struct{}
+
+func (MemoryStore) LoadGames() ([]*Poker, GameID, error)     { return nil, 1, nil }
+func (MemoryStore) SaveGame(*Poker) error                    { return nil }
+func (MemoryStore) DeleteGame(GameID) error                  { return nil }
+func (MemoryStore) SaveNextGameID(GameID) error              { return nil }
+func (MemoryStore) LoadPlayers() ([]Player, PlayerID, error) { return nil, 1, nil }
+func (MemoryStore) SavePlayer(Player) error                  { return nil }
+func (MemoryStore) SaveNextPlayerID(PlayerID) error          { return nil }
+func (MemoryStore) LoadSessions() ([]SavedSession, error)    { return nil, nil }
+func (MemoryStore) SaveSession(SavedSession) error           { return nil }
+func (MemoryStore) DeleteSession(string) error               { return nil }
+func (MemoryStore) Close() error                             { return nil }
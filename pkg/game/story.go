@@ -0,0 +1,291 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrStoryNotFound = errors.New("story not found")
+var ErrNoNextStory = errors.New("there is no next story")
+var ErrInvalidStoryOrder = errors.New("story order must contain every story of the game exactly once")
+
+type StoryID uint64
+
+// Story is an item of a game's backlog that players estimate.
+type Story struct {
+	ID          StoryID `json:"id"`
+	Key         string  `json:"key"` // e.g. an issue key in a tracker
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Link        string  `json:"link,omitempty"` // URL of the story, e.g. in a tracker
+	Estimate    Vote    `json:"estimate"`       // final agreed estimate, empty until it is set
+}
+
+// Round is a completed round of voting.
+type Round struct {
+	StoryID StoryID           `json:"storyId"` // 0 if no story was selected during the round
+	Number  int               `json:"number"`  // starts from 1 for every story
+	Votes   []PlayerRoundVote `json:"votes"`
+	// Skipped are players who could vote in the round but didn't, with an empty Vote. Rounds completed before this
+	// was recorded don't have it.
+	Skipped    []PlayerRoundVote `json:"skipped,omitempty"`
+	Stats      RoundStats        `json:"stats"`
+	RevealedAt time.Time         `json:"revealedAt"`
+}
+
+// PlayerRoundVote is a vote in a completed round. Player's name is kept in case they leave the game.
+type PlayerRoundVote struct {
+	PlayerID   PlayerID `json:"playerId"`
+	PlayerName string   `json:"playerName"`
+	Vote       Vote     `json:"vote"`
+}
+
+// AddStory appends a story to the end of the game's backlog. If no story is being estimated, the new one becomes
+// current. The backlog is managed only by the facilitator, as are all the other changes of stories.
+func (d *Dealer) AddStory(gameID GameID, playerID PlayerID, req StoryRequest) (Story, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return Story{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return Story{}, err
+	}
+	story := appendStory(game, req)
+	started := game.CurrentStoryID == 0
+	if started {
+		d.startStory(game, story.ID)
+	}
+	if err := d.saveGame(game); err != nil {
+		return Story{}, err
+	}
+	if started {
+		d.cancelTimer(game)
+	}
+	d.hub.Publish(NewEvent(EventStoryAdded, gameID, story))
+	if started {
+		d.hub.Publish(NewEvent(EventStoryStarted, gameID, StoryRef{StoryID: story.ID}))
+	}
+	return story, nil
+}
+
+// ReorderStories changes the order of the game's backlog. order must contain IDs of all stories of the game.
+func (d *Dealer) ReorderStories(gameID GameID, playerID PlayerID, order []StoryID) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	if len(order) != len(game.Stories) {
+		return GameResponse{}, ErrInvalidStoryOrder
+	}
+	byID := make(map[StoryID]Story, len(game.Stories))
+	for _, story := range game.Stories {
+		byID[story.ID] = story
+	}
+	stories := make([]Story, 0, len(order))
+	for _, id := range order {
+		story, ok := byID[id]
+		if !ok {
+			return GameResponse{}, ErrInvalidStoryOrder
+		}
+		delete(byID, id) // so the same ID can't be used twice
+		stories = append(stories, story)
+	}
+	game.Stories = stories
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.hub.Publish(NewEvent(EventStoriesReordered, gameID, order))
+	return gameToResponse(game), nil
+}
+
+// RemoveStory deletes a story with its rounds from the game. If the story was current, the next one in the backlog
+// becomes current.
+func (d *Dealer) RemoveStory(gameID GameID, playerID PlayerID, storyID StoryID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return err
+	}
+	i := storyIndex(game, storyID)
+	if i < 0 {
+		return &StoryNotFoundError{GameID: gameID, StoryID: storyID}
+	}
+	game.Stories = append(game.Stories[:i], game.Stories[i+1:]...)
+	rounds := game.History[:0]
+	for _, round := range game.History {
+		if round.StoryID != storyID {
+			rounds = append(rounds, round)
+		}
+	}
+	game.History = rounds
+	started := game.CurrentStoryID == storyID
+	if started {
+		var next StoryID
+		if i < len(game.Stories) {
+			next = game.Stories[i].ID
+		}
+		d.startStory(game, next)
+	}
+	if err := d.saveGame(game); err != nil {
+		return err
+	}
+	if started {
+		d.cancelTimer(game)
+	}
+	d.hub.Publish(NewEvent(EventStoryRemoved, gameID, StoryRef{StoryID: storyID}))
+	if started {
+		d.hub.Publish(NewEvent(EventStoryStarted, gameID, StoryRef{StoryID: game.CurrentStoryID}))
+	}
+	return nil
+}
+
+// NextStory starts estimating the story that follows the current one in the backlog.
+func (d *Dealer) NextStory(gameID GameID, playerID PlayerID) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	next := storyIndex(game, game.CurrentStoryID) + 1 // no current story means the first one is next
+	if next >= len(game.Stories) {
+		return GameResponse{}, ErrNoNextStory
+	}
+	d.startStory(game, game.Stories[next].ID)
+	if err := d.saveGame(game); err != nil {
+		return GameResponse{}, err
+	}
+	d.cancelTimer(game)
+	d.hub.Publish(NewEvent(EventStoryStarted, gameID, StoryRef{StoryID: game.CurrentStoryID}))
+	return gameToResponse(game), nil
+}
+
+// SetEstimate records the final agreed estimate of a story. Estimate must be a card of the game's deck.
+func (d *Dealer) SetEstimate(gameID GameID, playerID PlayerID, storyID StoryID, estimate Vote) (Story, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return Story{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return Story{}, err
+	}
+	i := storyIndex(game, storyID)
+	if i < 0 {
+		return Story{}, &StoryNotFoundError{GameID: gameID, StoryID: storyID}
+	}
+	if !game.Deck.Contains(estimate) {
+		return Story{}, &InvalidVoteError{Vote: estimate}
+	}
+	game.Stories[i].Estimate = estimate
+	if err := d.saveGame(game); err != nil {
+		return Story{}, err
+	}
+	d.hub.Publish(NewEvent(EventEstimateFinalized, gameID, game.Stories[i]))
+	return game.Stories[i], nil
+}
+
+// appendStory adds a story made from req to the end of the game's backlog.
+func appendStory(game *Poker, req StoryRequest) Story {
+	game.NextStoryID++
+	story := Story{
+		ID:          game.NextStoryID,
+		Key:         req.Key,
+		Title:       req.Title,
+		Description: req.Description,
+		Link:        req.Link,
+	}
+	game.Stories = append(game.Stories, story)
+	return story
+}
+
+// startStory makes a story current and starts a new round for it. storyID 0 means that no story is estimated. The game
+// must be locked.
+func (d *Dealer) startStory(game *Poker, storyID StoryID) {
+	game.CurrentStoryID = storyID
+	d.startRound(game)
+}
+
+// startRound drops the votes of the current round, so voting starts again. Every reset of a round goes through it and
+// is followed by cancelTimer once the game is saved, so a countdown of the previous round never reveals the new one.
+// The game must be locked.
+func (d *Dealer) startRound(game *Poker) {
+	game.Votes = map[PlayerID]Vote{}
+	game.State = RoundVoting
+}
+
+// completeRound adds the current round revealed at now to the game's history.
+func completeRound(game *Poker, now time.Time) {
+	number := 1
+	for _, round := range game.History {
+		if round.StoryID == game.CurrentStoryID {
+			number++
+		}
+	}
+	round := Round{
+		StoryID:    game.CurrentStoryID,
+		Number:     number,
+		Votes:      make([]PlayerRoundVote, 0, len(game.Votes)),
+		Stats:      ComputeStats(game.Deck, game.Votes),
+		RevealedAt: now,
+	}
+	for playerID, vote := range game.Votes {
+		round.Votes = append(round.Votes, PlayerRoundVote{
+			PlayerID:   playerID,
+			PlayerName: game.Players[playerID].Name,
+			Vote:       vote,
+		})
+	}
+	for playerID, player := range game.Players {
+		if _, voted := game.Votes[playerID]; !voted && game.Roles[playerID] != RoleObserver {
+			round.Skipped = append(round.Skipped, PlayerRoundVote{PlayerID: playerID, PlayerName: player.Name})
+		}
+	}
+	sort.Slice(round.Votes, func(i, j int) bool { return round.Votes[i].PlayerID < round.Votes[j].PlayerID })
+	sort.Slice(round.Skipped, func(i, j int) bool { return round.Skipped[i].PlayerID < round.Skipped[j].PlayerID })
+	game.History = append(game.History, round)
+}
+
+// allVotes returns the votes of the round followed by the empty votes of players who skipped it.
+func (r Round) allVotes() []PlayerRoundVote {
+	return append(append(make([]PlayerRoundVote, 0, len(r.Votes)+len(r.Skipped)), r.Votes...), r.Skipped...)
+}
+
+// storyIndex returns position of the story in the game's backlog or -1 if there is no such story.
+func storyIndex(game *Poker, storyID StoryID) int {
+	for i, story := range game.Stories {
+		if story.ID == storyID {
+			return i
+		}
+	}
+	return -1
+}
+
+func storiesToResponse(poker *Poker) ([]StoryResponse, []Round) {
+	stories := make([]StoryResponse, 0, len(poker.Stories))
+	byID := make(map[StoryID]int, len(poker.Stories))
+	for i, story := range poker.Stories {
+		stories = append(stories, StoryResponse{Story: story, Rounds: []Round{}})
+		byID[story.ID] = i
+	}
+	unassigned := make([]Round, 0)
+	for _, round := range poker.History {
+		if i, ok := byID[round.StoryID]; ok {
+			stories[i].Rounds = append(stories[i].Rounds, round)
+		} else {
+			unassigned = append(unassigned, round)
+		}
+	}
+	return stories, unassigned
+}
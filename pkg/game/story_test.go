@@ -0,0 +1,185 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+)
+
+func TestStories(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+
+	first := addStory(t, creator.Token, gameID, game.StoryRequest{Key: "GP-1", Title: "Login", Description: "Login with password"})
+	second := addStory(t, creator.Token, gameID, game.StoryRequest{Key: "GP-2", Title: "Logout"})
+	third := addStory(t, creator.Token, gameID, game.StoryRequest{Title: "Signup"})
+	poker := getGame(t, gameID)
+	require.Equal(t, first.ID, poker.CurrentStoryID) // the first added story is current
+	require.Equal(t, []game.StoryResponse{
+		{Story: first, Rounds: []game.Round{}},
+		{Story: second, Rounds: []game.Round{}},
+		{Story: third, Rounds: []game.Round{}},
+	}, poker.Stories)
+
+	// two rounds for the first story
+	vote(t, creator, "3", gameID)
+	reveal(t, creator.Token, gameID)
+	newRound(t, creator.Token, gameID)
+	vote(t, creator, "5", gameID)
+	reveal(t, creator.Token, gameID)
+	first = setEstimate(t, creator.Token, gameID, first.ID, "5")
+	require.Equal(t, game.Vote("5"), first.Estimate)
+
+	// reorder and move on
+	var resp *http.Response
+	resp = doJSON(t, creator.Token, http.MethodPut, fmt.Sprintf("/api/games/%d/stories", gameID),
+		game.ReorderStoriesRequest{StoryIDs: []game.StoryID{first.ID, third.ID, second.ID}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodPost, fmt.Sprintf("/api/games/%d/stories/next", gameID), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	require.Equal(t, third.ID, poker.CurrentStoryID)
+	require.Equal(t, game.RoundVoting, poker.State)
+	require.False(t, poker.Players[0].Voted)
+
+	require.Len(t, poker.Stories, 3)
+	require.Equal(t, first.ID, poker.Stories[0].ID)
+	require.Equal(t, third.ID, poker.Stories[1].ID)
+	require.Equal(t, second.ID, poker.Stories[2].ID)
+	rounds := poker.Stories[0].Rounds
+	require.Len(t, rounds, 2)
+	require.Equal(t, 1, rounds[0].Number)
+	require.Equal(t, []game.PlayerRoundVote{{PlayerID: creator.ID, PlayerName: creator.Name, Vote: "3"}}, rounds[0].Votes)
+	require.Equal(t, 2, rounds[1].Number)
+	require.Equal(t, []game.PlayerRoundVote{{PlayerID: creator.ID, PlayerName: creator.Name, Vote: "5"}}, rounds[1].Votes)
+	require.True(t, rounds[1].Stats.Consensus)
+	require.Empty(t, poker.Rounds)
+
+	// removing the current story moves to the next one
+	resp = doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/stories/%d", gameID, third.ID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	poker = getGame(t, gameID)
+	require.Equal(t, second.ID, poker.CurrentStoryID)
+	require.Len(t, poker.Stories, 2)
+
+	// there is nothing after the last story
+	resp = doJSON(t, creator.Token, http.MethodPost, fmt.Sprintf("/api/games/%d/stories/next", gameID), nil)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestRoundsWithoutStory(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	vote(t, creator, "8", gameID)
+	poker := reveal(t, creator.Token, gameID)
+	require.Empty(t, poker.Stories)
+	require.Len(t, poker.Rounds, 1)
+	require.Equal(t, game.StoryID(0), poker.Rounds[0].StoryID)
+	require.Equal(t, game.Vote("8"), poker.Rounds[0].Votes[0].Vote)
+}
+
+func TestStoriesErrors(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	story := addStory(t, creator.Token, gameID, game.StoryRequest{Title: "Login"})
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         any
+		responseCode int
+	}{
+		{
+			name:         "story without title",
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/stories", gameID),
+			body:         game.StoryRequest{Key: "GP-1"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "add story to missing game",
+			method:       http.MethodPost,
+			path:         "/api/games/100/stories",
+			body:         game.StoryRequest{Title: "Login"},
+			responseCode: http.StatusNotFound,
+		},
+		{
+			name:         "order with missing story",
+			method:       http.MethodPut,
+			path:         fmt.Sprintf("/api/games/%d/stories", gameID),
+			body:         game.ReorderStoriesRequest{StoryIDs: []game.StoryID{}},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "order with unknown story",
+			method:       http.MethodPut,
+			path:         fmt.Sprintf("/api/games/%d/stories", gameID),
+			body:         game.ReorderStoriesRequest{StoryIDs: []game.StoryID{story.ID + 1}},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "remove unknown story",
+			method:       http.MethodDelete,
+			path:         fmt.Sprintf("/api/games/%d/stories/%d", gameID, story.ID+1),
+			responseCode: http.StatusNotFound,
+		},
+		{
+			name:         "estimate not in deck",
+			method:       http.MethodPut,
+			path:         fmt.Sprintf("/api/games/%d/stories/%d/estimate", gameID, story.ID),
+			body:         game.EstimateRequest{Estimate: "4"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "estimate unknown story",
+			method:       http.MethodPut,
+			path:         fmt.Sprintf("/api/games/%d/stories/%d/estimate", gameID, story.ID+1),
+			body:         game.EstimateRequest{Estimate: "3"},
+			responseCode: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := doJSON(t, creator.Token, test.method, test.path, test.body)
+			require.Equal(t, test.responseCode, resp.StatusCode)
+		})
+	}
+}
+
+func addStory(t *testing.T, token string, gameID game.GameID, req game.StoryRequest) game.Story {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/stories", gameID), req)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var story game.Story
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&story))
+	return story
+}
+
+func setEstimate(t *testing.T, token string, gameID game.GameID, storyID game.StoryID, estimate game.Vote) game.Story {
+	resp := doJSON(t, token, http.MethodPut, fmt.Sprintf("/api/games/%d/stories/%d/estimate", gameID, storyID),
+		game.EstimateRequest{Estimate: estimate})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var story game.Story
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&story))
+	return story
+}
+
+func newRound(t *testing.T, token string, gameID game.GameID) {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/round", gameID), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// doJSON sends body encoded as JSON, nil body is sent as an empty one.
@@ -0,0 +1,197 @@
+package game
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// TimerAction is what happens to the round when its timer runs out.
+type TimerAction string
+
+const (
+	TimerActionNone   TimerAction = "none"   // only EventTimerExpired is published
+	TimerActionReveal TimerAction = "reveal" // votes are revealed as if the facilitator did it
+	TimerActionClose  TimerAction = "close"  // no more voting, votes stay hidden until the facilitator reveals them
+)
+
+// timerTick is how often subscribers are reminded of a running timer.
+const timerTick = time.Second
+
+// RoundTimer is a countdown of the current voting round. The server owns the deadline, clients only show it.
+type RoundTimer struct {
+	StartedAt time.Time   `json:"startedAt"`
+	Deadline  time.Time   `json:"deadline"`
+	OnExpiry  TimerAction `json:"onExpiry"`
+}
+
+// TimerEvent is a payload of timer events. ServerTime is when the event was made, so clients with a clock that is off
+// can still count down to Deadline correctly.
+type TimerEvent struct {
+	RoundTimer
+	Remaining  float64   `json:"remaining"` // seconds left until Deadline
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// countdown runs a RoundTimer of a game. It's stopped by closing stop.
+type countdown struct {
+	timer  *RoundTimer
+	expiry ClockTimer  // fires at the deadline
+	ticker ClockTicker // every timerTick
+	stop   chan struct{}
+}
+
+// StartTimer starts a countdown of the current round that ends after duration. A running timer of the game is
+// replaced. Only the facilitator can start a timer and only while players vote.
+func (d *Dealer) StartTimer(gameID GameID, playerID PlayerID, duration time.Duration, onExpiry TimerAction) (GameResponse, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return GameResponse{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return GameResponse{}, err
+	}
+	if game.State != RoundVoting {
+		return GameResponse{}, ErrVotingClosed
+	}
+	if onExpiry == "" {
+		onExpiry = TimerActionNone
+	}
+	if err := d.saveGame(game); err != nil { // timers are not saved, but starting one is an activity
+		return GameResponse{}, err
+	}
+	d.cancelTimer(game)
+	now := d.clock.Now()
+	game.Timer = &RoundTimer{StartedAt: now, Deadline: now.Add(duration), OnExpiry: onExpiry}
+	c := &countdown{timer: game.Timer, stop: make(chan struct{})}
+	d.countdownLock.Lock()
+	if d.closed {
+		d.countdownLock.Unlock()
+		game.Timer = nil
+		return GameResponse{}, ErrDealerClosed
+	}
+	// the clock measures the countdown from now on, even before the goroutine runs
+	c.expiry, c.ticker = d.clock.NewTimer(duration), d.clock.NewTicker(timerTick)
+	d.countdowns[gameID] = c
+	d.countdownsRunning.Add(1)
+	d.countdownLock.Unlock()
+	go d.runCountdown(gameID, c)
+
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "duration": duration, "on_expiry": onExpiry}).Info("Timer started")
+	d.hub.Publish(NewEvent(EventTimerStarted, gameID, d.timerEvent(game.Timer)))
+	return gameToResponse(game), nil
+}
+
+// StopTimer cancels the running timer of the game, if there is one. Only the facilitator can stop a timer.
+func (d *Dealer) StopTimer(gameID GameID, playerID PlayerID) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return err
+	}
+	d.cancelTimer(game)
+	return nil
+}
+
+// cancelTimer stops the timer of the game and lets subscribers know. It does nothing if no timer runs. The game must
+// be locked.
+func (d *Dealer) cancelTimer(game *Poker) {
+	if game.Timer == nil {
+		return
+	}
+	d.countdownLock.Lock()
+	if c, ok := d.countdowns[game.ID]; ok {
+		close(c.stop)
+		delete(d.countdowns, game.ID)
+	}
+	d.countdownLock.Unlock()
+	timer := game.Timer
+	game.Timer = nil
+	d.hub.Publish(NewEvent(EventTimerCancelled, game.ID, d.timerEvent(timer)))
+}
+
+// runCountdown reminds subscribers of the timer every timerTick until it expires or is stopped.
+func (d *Dealer) runCountdown(gameID GameID, c *countdown) {
+	defer d.countdownsRunning.Done()
+	defer c.expiry.Stop()
+	defer c.ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.ticker.C():
+			d.tickTimer(gameID, c)
+		case <-c.expiry.C():
+			if err := d.expireTimer(gameID, c); err != nil {
+				d.log.WithField("game_id", gameID).WithError(err).Error("Failed to expire timer")
+			}
+			return
+		}
+	}
+}
+
+func (d *Dealer) tickTimer(gameID GameID, c *countdown) {
+	game, unlock, err := d.readGame(gameID)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if game.Timer == c.timer { // otherwise the timer was stopped while waiting for the lock
+		d.hub.Publish(NewEvent(EventTimerTick, gameID, d.timerEvent(c.timer)))
+	}
+}
+
+// expireTimer ends the round as the timer says. The timer may have been stopped while waiting for the lock of the
+// game, then nothing happens.
+func (d *Dealer) expireTimer(gameID GameID, c *countdown) error {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return nil // deleted or archived, the timer was stopped with it
+	}
+	defer unlock()
+	if game.Timer != c.timer {
+		return nil
+	}
+	d.countdownLock.Lock()
+	delete(d.countdowns, gameID)
+	d.countdownLock.Unlock()
+	game.Timer = nil
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "on_expiry": c.timer.OnExpiry}).Info("Timer expired")
+	d.hub.Publish(NewEvent(EventTimerExpired, gameID, d.timerEvent(c.timer)))
+	switch c.timer.OnExpiry {
+	case TimerActionReveal:
+		_, err = d.revealRound(game)
+		return err
+	case TimerActionClose:
+		game.State = RoundClosed
+		if err := d.saveGame(game); err != nil {
+			return err
+		}
+		d.hub.Publish(NewEvent(EventVotingClosed, gameID, nil))
+	}
+	return nil
+}
+
+// stopTimers stops all countdowns and waits for them to return. Timers can't be started after that.
+func (d *Dealer) stopTimers() {
+	d.countdownLock.Lock()
+	d.closed = true
+	for gameID, c := range d.countdowns {
+		close(c.stop)
+		delete(d.countdowns, gameID)
+	}
+	d.countdownLock.Unlock()
+	d.countdownsRunning.Wait()
+}
+
+func (d *Dealer) timerEvent(timer *RoundTimer) TimerEvent {
+	now := d.clock.Now()
+	remaining := timer.Deadline.Sub(now).Seconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return TimerEvent{RoundTimer: *timer, Remaining: remaining, ServerTime: now}
+}
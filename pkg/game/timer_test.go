@@ -0,0 +1,212 @@
+package game_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTimerRevealsRound(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, timerConfig(clock))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	vote(t, creator, "5", gameID)
+	conn := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+
+	poker := startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 1, OnExpiry: game.TimerActionReveal})
+	require.NotNil(t, poker.Timer)
+	require.Equal(t, game.TimerActionReveal, poker.Timer.OnExpiry)
+	require.Equal(t, time.Second, poker.Timer.Deadline.Sub(poker.Timer.StartedAt))
+
+	event := readEvent(t, conn)
+	require.Equal(t, game.EventTimerStarted, event.Type)
+	var started game.TimerEvent
+	require.NoError(t, json.Unmarshal(event.Payload, &started))
+	require.True(t, poker.Timer.Deadline.Equal(started.Deadline))
+	require.Equal(t, float64(1), started.Remaining)
+	require.True(t, clock.Now().Equal(started.ServerTime))
+
+	clock.Advance(time.Second)
+	event = readEventSkippingTicks(t, conn)
+	require.Equal(t, game.EventTimerExpired, event.Type)
+	event = readEvent(t, conn)
+	require.Equal(t, game.EventRoundRevealed, event.Type)
+	poker = getGame(t, gameID)
+	require.Equal(t, game.RoundRevealed, poker.State)
+	require.Nil(t, poker.Timer)
+	require.Len(t, poker.Rounds, 1)
+}
+
+func TestTimerClosesVoting(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, timerConfig(clock))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	conn := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+
+	startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 1, OnExpiry: game.TimerActionClose})
+	require.Equal(t, game.EventTimerStarted, readEvent(t, conn).Type)
+	vote(t, creator, "3", gameID)
+	require.Equal(t, game.EventVoteCast, readEvent(t, conn).Type)
+	clock.Advance(time.Second)
+	require.Equal(t, game.EventTimerExpired, readEventSkippingTicks(t, conn).Type)
+	require.Equal(t, game.EventVotingClosed, readEvent(t, conn).Type)
+
+	poker := getGame(t, gameID)
+	require.Equal(t, game.RoundClosed, poker.State)
+	require.Empty(t, poker.Players[0].Vote)
+	voteExpect(t, creator.Token, "5", gameID, http.StatusConflict)
+	poker = reveal(t, creator.Token, gameID)
+	require.Equal(t, game.Vote("3"), poker.Players[0].Vote)
+}
+
+func TestTimerIsCancelled(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, timerConfig(clock))
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	conn := dialGame(t, gameID)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, conn).Type)
+	path := fmt.Sprintf("/api/games/%d/timer", gameID)
+
+	resp := doJSON(t, voter.Token, http.MethodPost, path, game.TimerRequest{Seconds: 60})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodPost, path, game.TimerRequest{})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodPost, path, game.TimerRequest{Seconds: 60, OnExpiry: "explode"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 60})
+	expectEvents(t, conn, game.EventTimerStarted)
+	clock.Advance(time.Second)
+	event := readEvent(t, conn)
+	require.Equal(t, game.EventTimerTick, event.Type)
+	var tick game.TimerEvent
+	require.NoError(t, json.Unmarshal(event.Payload, &tick))
+	require.Equal(t, float64(59), tick.Remaining)
+	resp = doJSON(t, creator.Token, http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	expectEvents(t, conn, game.EventTimerCancelled)
+	require.Nil(t, getGame(t, gameID).Timer)
+
+	startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 60})
+	poker := startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 30})
+	require.Equal(t, 30*time.Second, poker.Timer.Deadline.Sub(poker.Timer.StartedAt))
+	expectEvents(t, conn, game.EventTimerStarted, game.EventTimerCancelled, game.EventTimerStarted)
+
+	newRound(t, creator.Token, gameID)
+	expectEvents(t, conn, game.EventTimerCancelled, game.EventRoundStarted)
+
+	startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 60})
+	reveal(t, creator.Token, gameID)
+	expectEvents(t, conn, game.EventTimerStarted, game.EventTimerCancelled, game.EventRoundRevealed)
+	resp = doJSON(t, creator.Token, http.MethodPost, path, game.TimerRequest{Seconds: 60})
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	newRound(t, creator.Token, gameID)
+	startTimer(t, creator.Token, gameID, game.TimerRequest{Seconds: 60})
+	resp = doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", gameID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	expectEvents(t, conn,
+		game.EventRoundStarted, game.EventTimerStarted, game.EventTimerCancelled, game.EventGameDeleted)
+
+	// stopping the server stops running timers too
+	startTimer(t, creator.Token, createDefaultGame(t, creator), game.TimerRequest{Seconds: 60})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, srv.Stop(ctx))
+}
+
+func TestTimerIsCancelledByFirstStory(t *testing.T) {
+	clock := newFakeClock()
+	srv := startServerWithConfig(t, timerConfig(clock))
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	added := createDefaultGame(t, creator)
+	imported := createDefaultGame(t, creator)
+	addedConn := dialGame(t, added)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, addedConn).Type)
+	importedConn := dialGame(t, imported)
+	require.Equal(t, game.EventGameSnapshot, readEvent(t, importedConn).Type)
+
+	startTimer(t, creator.Token, added, game.TimerRequest{Seconds: 1, OnExpiry: game.TimerActionReveal})
+	vote(t, creator, "5", added)
+	addStory(t, creator.Token, added, game.StoryRequest{Title: "Login"})
+	expectEvents(t, addedConn, game.EventTimerStarted, game.EventVoteCast,
+		game.EventTimerCancelled, game.EventStoryAdded, game.EventStoryStarted)
+
+	startTimer(t, creator.Token, imported, game.TimerRequest{Seconds: 1, OnExpiry: game.TimerActionClose})
+	resp := importStories(t, creator.Token, imported, "", "application/json", `[{"title": "Login"}]`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	expectEvents(t, importedConn, game.EventTimerStarted,
+		game.EventTimerCancelled, game.EventStoriesImported, game.EventStoryStarted)
+
+	// neither round is revealed or closed when the timers would have expired
+	clock.Advance(2 * time.Second)
+	for _, conn := range []*websocket.Conn{addedConn, importedConn} {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+		var event game.Event
+		require.Error(t, conn.ReadJSON(&event), "unexpected %s", event.Type)
+	}
+	for _, gameID := range []game.GameID{added, imported} {
+		poker := getGame(t, gameID)
+		require.Equal(t, game.RoundVoting, poker.State)
+		require.Nil(t, poker.Timer)
+		require.Empty(t, poker.Rounds)
+	}
+}
+
+// timerConfig makes timers of the server count down only when the clock is advanced.
+func timerConfig(clock *fakeClock) game.Config {
+	cfg := game.DefaultConfig()
+	cfg.Clock = clock
+	return cfg
+}
+
+func startTimer(t *testing.T, token string, gameID game.GameID, req game.TimerRequest) game.GameResponse {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/timer", gameID), req)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var poker game.GameResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&poker))
+	return poker
+}
+
+// readEventSkippingTicks waits for the first event that is not EventTimerTick.
+func readEventSkippingTicks(t *testing.T, conn *websocket.Conn) game.Event {
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		var event game.Event
+		require.NoError(t, conn.ReadJSON(&event))
+		if event.Type != game.EventTimerTick {
+			return event
+		}
+		var tick game.TimerEvent
+		require.NoError(t, json.Unmarshal(event.Payload, &tick))
+		require.LessOrEqual(t, tick.Remaining, float64(1))
+	}
+}
+
+// expectEvents reads events of the given types in order.
+func expectEvents(t *testing.T, conn *websocket.Conn, types ...game.EventType) {
+	for _, expected := range types {
+		require.Equal(t, expected, readEvent(t, conn).Type)
+	}
+}
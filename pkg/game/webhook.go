@@ -0,0 +1,598 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+var ErrInvalidWebhook = errors.New("invalid webhook")
+var ErrTooManyWebhooks = fmt.Errorf("a game can't have more than %d webhooks", maxGameWebhooks)
+
+// Headers of webhook requests.
+const (
+	WebhookSignatureHeader = "X-Gpoker-Signature" // see SignWebhook
+	WebhookTimestampHeader = "X-Gpoker-Timestamp" // Unix time of the attempt in seconds, signed with the body
+	WebhookEventHeader     = "X-Gpoker-Event"
+	WebhookDeliveryHeader  = "X-Gpoker-Delivery" // the same for every attempt of a delivery
+)
+
+// maxWebhookDeliveries is how many latest deliveries are kept in the log of every webhook.
+const maxWebhookDeliveries = 50
+
+// maxGameWebhooks is how many webhooks a single game can have.
+const maxGameWebhooks = 10
+
+type WebhookID uint64
+
+// WebhookTarget is where events are sent.
+type WebhookTarget struct {
+	URL    string `json:"url" yaml:"url"`
+	Secret string `json:"secret,omitempty" yaml:"secret"` // signs requests, see WebhookSignatureHeader
+	// Events are the types of events to send. Every event except EventTimerTick is sent if it's empty.
+	Events []EventType `json:"events" yaml:"events"`
+}
+
+// WebhooksConfig configures webhooks of a Server.
+type WebhooksConfig struct {
+	Global []WebhookTarget `json:"global" yaml:"global"` // get events of every game
+	// GameHosts are hosts that facilitators can send events of their games to, e.g. "hooks.example.com",
+	// "*.example.com" or "*" for any. Games can't have webhooks if it's empty. Loopback, private and link-local
+	// addresses are refused even then, unless they are listed here as IP addresses.
+	GameHosts   []string      `json:"gameHosts" yaml:"gameHosts"`
+	MaxAttempts int           `json:"maxAttempts" yaml:"maxAttempts"` // of a single delivery
+	Backoff     time.Duration `json:"backoff" yaml:"backoff"`         // before the first retry, doubled for every next
+	MaxBackoff  time.Duration `json:"maxBackoff" yaml:"maxBackoff"`   // between retries, Backoff stops doubling at it
+	Timeout     time.Duration `json:"timeout" yaml:"timeout"`         // of a single attempt
+}
+
+func (cfg WebhooksConfig) validate() error {
+	if cfg.MaxAttempts <= 0 {
+		return fmt.Errorf("webhook attempts must be positive, got %d", cfg.MaxAttempts)
+	}
+	if cfg.Backoff < 0 || cfg.Timeout <= 0 {
+		return fmt.Errorf("webhook backoff can't be negative and timeout must be positive, got %s and %s",
+			cfg.Backoff, cfg.Timeout)
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		return fmt.Errorf("webhook max backoff can't be less than backoff, got %s and %s", cfg.MaxBackoff, cfg.Backoff)
+	}
+	for _, target := range cfg.Global {
+		if err := target.validate(); err != nil {
+			return err
+		}
+	}
+	for _, host := range cfg.GameHosts {
+		if !validGameHost(host) {
+			return fmt.Errorf("webhook game hosts must be host names or IP addresses, got %q", host)
+		}
+	}
+	return nil
+}
+
+// Webhook of a game. Its secret is shown only when the webhook is added.
+type Webhook struct {
+	ID WebhookID `json:"id"`
+	WebhookTarget
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DeliveryStatus tells whether a webhook delivery succeeded.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending" // the first attempt is in progress or a retry is waiting
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed means every attempt failed or the error can't be fixed by retrying. A delivery without attempts
+	// failed because too many were waiting for the webhook or the server was shutting down.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event sent to a webhook, with all attempts to send it.
+type WebhookDelivery struct {
+	ID        string            `json:"id"`
+	Event     EventType         `json:"event"`
+	EventTime time.Time         `json:"eventTime"`
+	Status    DeliveryStatus    `json:"status"`
+	Attempts  []DeliveryAttempt `json:"attempts"`
+}
+
+// DeliveryAttempt is a single request to a webhook.
+type DeliveryAttempt struct {
+	At         time.Time     `json:"at"`
+	Duration   time.Duration `json:"duration"` // in nanoseconds
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// AddWebhook makes the game send its events to a URL. A random secret is generated unless req has one. Only the
+// facilitator can manage webhooks of a game.
+func (d *Dealer) AddWebhook(gameID GameID, playerID PlayerID, req WebhookRequest) (Webhook, error) {
+	game, unlock, err := d.lockGame(gameID)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return Webhook{}, err
+	}
+	if len(game.Webhooks) >= maxGameWebhooks {
+		return Webhook{}, ErrTooManyWebhooks
+	}
+	target := WebhookTarget{URL: req.URL, Secret: req.Secret, Events: req.Events}
+	if err := target.validate(); err != nil {
+		return Webhook{}, err
+	}
+	if err := d.webhooks.checkGameTarget(target); err != nil {
+		return Webhook{}, err
+	}
+	if target.Secret == "" {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return Webhook{}, err
+		}
+		target.Secret = hex.EncodeToString(secret)
+	}
+	game.NextWebhookID++
+	hook := Webhook{ID: game.NextWebhookID, WebhookTarget: target, CreatedAt: d.clock.Now()}
+	game.Webhooks = append(game.Webhooks, hook)
+	if err := d.saveGame(game); err != nil {
+		return Webhook{}, err
+	}
+	d.webhooks.setGameHooks(gameID, game.Webhooks)
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "webhook_id": hook.ID}).Info("Webhook added")
+	return hook, nil
+}
+
+// Webhooks returns webhooks of the game without their secrets.
+func (d *Dealer) Webhooks(gameID GameID, playerID PlayerID) ([]Webhook, error) {
+	game, unlock, err := d.readGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return nil, err
+	}
+	hooks := make([]Webhook, 0, len(game.Webhooks))
+	for _, hook := range game.Webhooks {
+		hook.Secret = ""
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// RemoveWebhook stops sending events of the game to a webhook.
+func (d *Dealer) RemoveWebhook(gameID GameID, playerID PlayerID, webhookID WebhookID) error {
+	game, unlock, err := d.lockAnyGame(gameID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return err
+	}
+	i := webhookIndex(game, webhookID)
+	if i < 0 {
+		return ErrWebhookNotFound
+	}
+	game.Webhooks = append(game.Webhooks[:i], game.Webhooks[i+1:]...)
+	if err := d.saveGame(game); err != nil {
+		return err
+	}
+	d.webhooks.setGameHooks(gameID, game.Webhooks)
+	d.log.WithFields(logrus.Fields{"game_id": gameID, "webhook_id": webhookID}).Info("Webhook removed")
+	return nil
+}
+
+// WebhookDeliveries returns the latest deliveries to a webhook of the game, the most recent first.
+func (d *Dealer) WebhookDeliveries(gameID GameID, playerID PlayerID, webhookID WebhookID) ([]WebhookDelivery, error) {
+	game, unlock, err := d.readGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := checkFacilitator(game, playerID); err != nil {
+		return nil, err
+	}
+	if webhookIndex(game, webhookID) < 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return d.webhooks.deliveryLog(webhookKey{gameID: gameID, id: webhookID}), nil
+}
+
+// GlobalWebhookDeliveries returns the latest deliveries to a global webhook, the most recent first. Global webhooks
+// are numbered from 1 in the order of WebhooksConfig.Global.
+func (d *Dealer) GlobalWebhookDeliveries(webhookID WebhookID) ([]WebhookDelivery, error) {
+	if d.webhooks == nil || webhookID == 0 || webhookID > WebhookID(len(d.webhooks.cfg.Global)) {
+		return nil, ErrWebhookNotFound
+	}
+	return d.webhooks.deliveryLog(webhookKey{id: webhookID}), nil
+}
+
+func webhookIndex(game *Poker, webhookID WebhookID) int {
+	for i, hook := range game.Webhooks {
+		if hook.ID == webhookID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t WebhookTarget) validate() error {
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an HTTP URL", ErrInvalidWebhook, t.URL)
+	}
+	for _, eventType := range t.Events {
+		if !webhookEvents[eventType] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, eventType)
+		}
+	}
+	return nil
+}
+
+// webhookEvents are events that can be sent to webhooks. Snapshots are only for websockets.
+var webhookEvents = map[EventType]bool{
+	EventGameCreated: true, EventPlayerJoined: true, EventPlayerLeft: true, EventGameDeleted: true,
+	EventGameExpired: true, EventVoteCast: true, EventRoundRevealed: true, EventRoundStarted: true,
+	EventRolesChanged: true, EventGameRenamed: true, EventStoryAdded: true, EventStoryRemoved: true,
+	EventStoriesReordered: true, EventStoryStarted: true, EventStoriesImported: true, EventEstimateFinalized: true,
+	EventTimerStarted: true, EventTimerTick: true, EventTimerExpired: true, EventTimerCancelled: true,
+	EventVotingClosed: true, EventSettingsChanged: true, EventPresenceChanged: true,
+}
+
+func (t WebhookTarget) wants(eventType EventType) bool {
+	if len(t.Events) == 0 {
+		return eventType != EventTimerTick
+	}
+	for _, wanted := range t.Events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookKey identifies a webhook among all games. Global webhooks have gameID 0 and are numbered from 1 in the
+// order of the configuration.
+type webhookKey struct {
+	gameID GameID
+	id     WebhookID
+}
+
+// maxQueuedDeliveries is how many deliveries can wait for a single webhook. Further ones fail without being sent.
+const maxQueuedDeliveries = 1000
+
+// queuedDelivery is a delivery waiting for its turn, see webhookSender.work.
+type queuedDelivery struct {
+	target   WebhookTarget
+	event    Event
+	delivery *WebhookDelivery
+}
+
+// webhookSender sends events published by the Hub to webhooks. It keeps copies of webhooks of all games, so it
+// never waits for locks of games. Every webhook has its own queue and a worker that sends its deliveries one by one,
+// so a webhook gets events in the order they were published and a slow one doesn't hold up others.
+type webhookSender struct {
+	cfg        WebhooksConfig
+	client     *http.Client // for global webhooks
+	gameClient *http.Client // for webhooks of games, see newGameWebhookClient
+	log        logrus.FieldLogger
+	clock      Clock
+	metrics    *Metrics // counts dropped deliveries, nil if not instrumented
+
+	hooks          map[GameID][]Webhook // webhooks of games
+	deliveries     map[webhookKey][]*WebhookDelivery
+	queues         map[webhookKey][]queuedDelivery // the first one is being sent, a webhook has a worker if it's here
+	nextDeliveryID uint64
+	lock           sync.Mutex // protects hooks, deliveries, queues, nextDeliveryID and the deliveries themselves
+
+	ctx     context.Context    // cancelled by close
+	stop    context.CancelFunc // cancels ctx
+	running sync.WaitGroup     // workers
+}
+
+// startWebhooks starts sending events to webhooks of games and to the global ones of cfg. Events are sent until the
+// Dealer is closed.
+func (d *Dealer) startWebhooks(cfg WebhooksConfig) {
+	ctx, stop := context.WithCancel(context.Background())
+	w := &webhookSender{
+		cfg:        cfg,
+		client:     newWebhookClient(cfg.Timeout, http.DefaultTransport),
+		gameClient: newGameWebhookClient(cfg),
+		log:        d.log,
+		clock:      d.clock,
+		metrics:    d.metrics,
+		hooks:      make(map[GameID][]Webhook),
+		deliveries: make(map[webhookKey][]*WebhookDelivery),
+		queues:     make(map[webhookKey][]queuedDelivery),
+		ctx:        ctx,
+		stop:       stop,
+	}
+	d.lock.RLock()
+	tables := make(map[GameID]*table, len(d.games))
+	for id, t := range d.games {
+		tables[id] = t
+	}
+	d.lock.RUnlock()
+	for id, t := range tables {
+		t.lock.RLock()
+		if len(t.poker.Webhooks) > 0 {
+			w.hooks[id] = append([]Webhook(nil), t.poker.Webhooks...)
+		}
+		t.lock.RUnlock()
+	}
+	d.webhooks = w
+	d.hub.Observe(w.enqueue)
+}
+
+// checkGameTarget is WebhooksConfig.checkGameTarget of the started webhooks. Games can't have webhooks before that.
+func (w *webhookSender) checkGameTarget(target WebhookTarget) error {
+	if w == nil {
+		return ErrWebhooksDisabled
+	}
+	return w.cfg.checkGameTarget(target)
+}
+
+// setGameHooks replaces the copy of webhooks of a game. It does nothing if webhooks are not started.
+func (w *webhookSender) setGameHooks(gameID GameID, hooks []Webhook) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(hooks) == 0 {
+		delete(w.hooks, gameID)
+		return
+	}
+	w.hooks[gameID] = append([]Webhook(nil), hooks...)
+}
+
+// close fails deliveries that are still waiting and waits for the workers. The hub must be closed already, so no
+// more deliveries are queued.
+func (w *webhookSender) close() {
+	if w == nil {
+		return
+	}
+	w.stop()
+	w.running.Wait()
+}
+
+// enqueue queues the event for every webhook that wants it. It's called by the Hub for every event, so it never
+// blocks: if a webhook has too many deliveries waiting, the new one fails at once.
+func (w *webhookSender) enqueue(event Event) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	targets := make(map[webhookKey]WebhookTarget)
+	for i, target := range w.cfg.Global {
+		targets[webhookKey{id: WebhookID(i + 1)}] = target
+	}
+	for _, hook := range w.hooks[event.GameID] {
+		targets[webhookKey{gameID: event.GameID, id: hook.ID}] = hook.WebhookTarget
+	}
+	for key, target := range targets {
+		if !target.wants(event.Type) {
+			continue
+		}
+		delivery := w.newDelivery(key, event)
+		queue := w.queues[key]
+		if len(queue) >= maxQueuedDeliveries {
+			delivery.Status = DeliveryFailed
+			w.metrics.webhookDeliveryDropped()
+			w.log.WithFields(logrus.Fields{
+				"game_id":     key.gameID,
+				"webhook_id":  key.id,
+				"delivery_id": delivery.ID,
+				"event":       delivery.Event,
+			}).Warn("Dropped webhook delivery, too many are waiting")
+			continue
+		}
+		w.queues[key] = append(queue, queuedDelivery{target: target, event: event, delivery: delivery})
+		if len(queue) == 0 {
+			w.running.Add(1)
+			go w.work(key)
+		}
+	}
+	if gameRemoved(event) {
+		delete(w.hooks, event.GameID)
+	}
+	if event.Type == EventGameDeleted || event.Type == EventGameExpired { // archived games don't keep their logs
+		for key := range w.deliveries {
+			if key.gameID == event.GameID {
+				delete(w.deliveries, key)
+			}
+		}
+	}
+}
+
+// work sends deliveries queued for a webhook in order until the queue is empty.
+func (w *webhookSender) work(key webhookKey) {
+	defer w.running.Done()
+	for {
+		w.lock.Lock()
+		next := w.queues[key][0]
+		w.lock.Unlock()
+		w.deliver(key, next)
+		w.lock.Lock()
+		queue := w.queues[key][1:]
+		if len(queue) == 0 {
+			delete(w.queues, key)
+			w.lock.Unlock()
+			return
+		}
+		w.queues[key] = queue
+		w.lock.Unlock()
+	}
+}
+
+// gameRemoved tells whether the event is the last one of a game that no longer exists.
+func gameRemoved(event Event) bool {
+	if event.Type == EventGameDeleted {
+		return true
+	}
+	if event.Type != EventGameExpired {
+		return false
+	}
+	var expired GameExpired
+	return json.Unmarshal(event.Payload, &expired) == nil && !expired.Archived
+}
+
+// newDelivery adds a pending delivery to the log of the webhook. w.lock must be held.
+func (w *webhookSender) newDelivery(key webhookKey, event Event) *WebhookDelivery {
+	w.nextDeliveryID++
+	delivery := &WebhookDelivery{
+		ID:        strconv.FormatUint(w.nextDeliveryID, 10),
+		Event:     event.Type,
+		EventTime: event.Time,
+		Status:    DeliveryPending,
+		Attempts:  []DeliveryAttempt{},
+	}
+	log := append(w.deliveries[key], delivery)
+	if len(log) > maxWebhookDeliveries {
+		log = log[len(log)-maxWebhookDeliveries:]
+	}
+	w.deliveries[key] = log
+	return delivery
+}
+
+// deliver sends the event until it is accepted, retrying with exponential backoff, or gives up.
+func (w *webhookSender) deliver(key webhookKey, queued queuedDelivery) {
+	delivery := queued.delivery
+	log := w.log.WithFields(logrus.Fields{
+		"game_id":     key.gameID,
+		"webhook_id":  key.id,
+		"delivery_id": delivery.ID,
+		"event":       delivery.Event,
+	})
+	fail := func() {
+		w.lock.Lock()
+		delivery.Status = DeliveryFailed
+		w.lock.Unlock()
+	}
+	if w.ctx.Err() != nil {
+		fail()
+		log.Warn("Webhook delivery cancelled by shutdown")
+		return
+	}
+	body, err := json.Marshal(queued.event)
+	if err != nil {
+		fail()
+		log.WithError(err).Error("Failed to encode webhook event")
+		return
+	}
+	backoff := w.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		at, started := w.clock.Now(), time.Now()
+		statusCode, err := w.post(w.ctx, key, queued.target, delivery, at, body)
+		w.lock.Lock()
+		record := DeliveryAttempt{At: at, Duration: time.Since(started), StatusCode: statusCode}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		delivery.Attempts = append(delivery.Attempts, record)
+		switch {
+		case err == nil:
+			delivery.Status = DeliveryDelivered
+		case !retryable(statusCode) || attempt >= w.cfg.MaxAttempts:
+			delivery.Status = DeliveryFailed
+		}
+		status := delivery.Status
+		w.lock.Unlock()
+		if status == DeliveryDelivered {
+			log.WithField("attempts", attempt).Debug("Webhook delivered")
+			return
+		}
+		if status == DeliveryFailed {
+			log.WithField("attempts", attempt).WithError(err).Warn("Webhook delivery failed")
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+			if backoff > w.cfg.MaxBackoff {
+				backoff = w.cfg.MaxBackoff
+			}
+		case <-w.ctx.Done():
+			fail()
+			log.Warn("Webhook delivery cancelled by shutdown")
+			return
+		}
+	}
+}
+
+// post makes a single attempt of a delivery. It returns the status code of the response, if there was one.
+func (w *webhookSender) post(ctx context.Context, key webhookKey, target WebhookTarget, delivery *WebhookDelivery,
+	at time.Time, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(at.Unix(), 10))
+	if target.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(target.Secret, at.Unix(), body))
+	}
+	client := w.client
+	if key.gameID != 0 {
+		client = w.gameClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable tells whether a failed attempt may succeed later. Requests that didn't get a response are retried.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusRequestTimeout
+}
+
+// deliveryLog returns copies of the deliveries to a webhook, the most recent first.
+func (w *webhookSender) deliveryLog(key webhookKey) []WebhookDelivery {
+	if w == nil {
+		return []WebhookDelivery{}
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	log := w.deliveries[key]
+	deliveries := make([]WebhookDelivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		delivery := *log[i]
+		delivery.Attempts = append([]DeliveryAttempt(nil), delivery.Attempts...)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+// SignWebhook returns the value of WebhookSignatureHeader for a body sent at timestamp, the value of
+// WebhookTimestampHeader: "sha256=" and hex HMAC-SHA256 of the timestamp, "." and the body keyed by the secret.
+// Receivers compute it the same way, compare it with hmac.Equal and reject old timestamps, so a captured request
+// can't be replayed.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,508 @@
+package game_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"gpoker/pkg/game"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	srv := startServerWithConfig(t, webhookConfig())
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	receiver := startReceiver(t, func(string) int { return http.StatusOK })
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+
+	hook := addWebhook(t, creator.Token, gameID, game.WebhookRequest{
+		URL:    receiver.URL + "/poker",
+		Secret: "s3cret",
+		Events: []game.EventType{game.EventPlayerJoined, game.EventVoteCast, game.EventRoundRevealed},
+	})
+	require.Equal(t, game.WebhookID(1), hook.ID)
+	require.Equal(t, "s3cret", hook.Secret)
+	generated := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/all"})
+	require.Len(t, generated.Secret, 32)
+
+	join(t, voter, gameID)
+	vote(t, voter, "5", gameID)
+	reveal(t, creator.Token, gameID)
+
+	// every webhook gets events in order, but the two webhooks may interleave
+	events := map[string][]game.EventType{}
+	for i := 0; i < 6; i++ {
+		received := receiver.next(t)
+		require.Equal(t, string(received.event.Type), received.header.Get(game.WebhookEventHeader))
+		require.NotEmpty(t, received.header.Get(game.WebhookDeliveryHeader))
+		require.Equal(t, gameID, received.event.GameID)
+		secret := hook.Secret
+		if received.path == "/all" {
+			secret = generated.Secret
+		}
+		timestamp, err := strconv.ParseInt(received.header.Get(game.WebhookTimestampHeader), 10, 64)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), time.Unix(timestamp, 0), time.Minute)
+		require.True(t, hmac.Equal([]byte(game.SignWebhook(secret, timestamp, received.body)),
+			[]byte(received.header.Get(game.WebhookSignatureHeader))))
+		require.NotEqual(t, game.SignWebhook(secret, timestamp+1, received.body),
+			received.header.Get(game.WebhookSignatureHeader))
+		events[received.path] = append(events[received.path], received.event.Type)
+	}
+	expected := []game.EventType{game.EventPlayerJoined, game.EventVoteCast, game.EventRoundRevealed}
+	require.Equal(t, expected, events["/poker"])
+	require.Equal(t, expected, events["/all"])
+
+	resp := doJSON(t, creator.Token, http.MethodGet, fmt.Sprintf("/api/games/%d/webhooks", gameID), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var hooks []game.Webhook
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&hooks))
+	require.Len(t, hooks, 2)
+	require.Equal(t, receiver.URL+"/poker", hooks[0].URL)
+	require.Empty(t, hooks[0].Secret)
+	require.Empty(t, hooks[1].Secret)
+
+	var deliveries []game.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries = webhookDeliveries(t, creator.Token, gameID, hook.ID)
+		for _, delivery := range deliveries {
+			if delivery.Status != game.DeliveryDelivered {
+				return false
+			}
+		}
+		return len(deliveries) == 3
+	}, time.Second, 10*time.Millisecond)
+	for _, delivery := range deliveries {
+		require.Len(t, delivery.Attempts, 1)
+		require.Equal(t, http.StatusOK, delivery.Attempts[0].StatusCode)
+		require.Empty(t, delivery.Attempts[0].Error)
+	}
+
+	resp = doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d/webhooks/%d", gameID, hook.ID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = doJSON(t, creator.Token, http.MethodGet,
+		fmt.Sprintf("/api/games/%d/webhooks/%d/deliveries", gameID, hook.ID), nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	newRound(t, creator.Token, gameID)
+	require.Equal(t, "/all", receiver.next(t).path)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	cfg := webhookConfig()
+	cfg.Webhooks.MaxAttempts = 3
+	cfg.Webhooks.Backoff = 10 * time.Millisecond
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	var lock sync.Mutex
+	attempts := map[string]int{}
+	receiver := startReceiver(t, func(path string) int {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[path]++
+		switch {
+		case path == "/flaky" && attempts[path] <= 2:
+			return http.StatusInternalServerError
+		case path == "/down":
+			return http.StatusServiceUnavailable
+		case path == "/rejecting":
+			return http.StatusBadRequest
+		default:
+			return http.StatusNoContent
+		}
+	})
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	events := []game.EventType{game.EventRoundStarted}
+	flaky := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/flaky", Events: events})
+	down := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/down", Events: events})
+	rejecting := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/rejecting", Events: events})
+
+	newRound(t, creator.Token, gameID)
+
+	tests := []struct {
+		hook        game.Webhook
+		status      game.DeliveryStatus
+		statusCodes []int
+	}{
+		{flaky, game.DeliveryDelivered, []int{500, 500, 204}},
+		{down, game.DeliveryFailed, []int{503, 503, 503}},
+		{rejecting, game.DeliveryFailed, []int{400}},
+	}
+	for _, test := range tests {
+		var deliveries []game.WebhookDelivery
+		require.Eventually(t, func() bool {
+			deliveries = webhookDeliveries(t, creator.Token, gameID, test.hook.ID)
+			return len(deliveries) == 1 && deliveries[0].Status != game.DeliveryPending
+		}, 2*time.Second, 10*time.Millisecond, test.hook.URL)
+		require.Equal(t, game.EventRoundStarted, deliveries[0].Event)
+		require.Equal(t, test.status, deliveries[0].Status, test.hook.URL)
+		var statusCodes []int
+		for _, attempt := range deliveries[0].Attempts {
+			statusCodes = append(statusCodes, attempt.StatusCode)
+			require.Equal(t, attempt.StatusCode >= 300, attempt.Error != "")
+		}
+		require.Equal(t, test.statusCodes, statusCodes, test.hook.URL)
+	}
+}
+
+func TestWebhookOrder(t *testing.T) {
+	srv := startServerWithConfig(t, webhookConfig())
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	receiver := startReceiver(t, func(path string) int {
+		if path == "/slow" {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return http.StatusOK
+	})
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	events := []game.EventType{game.EventGameRenamed}
+	addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/slow", Events: events})
+	addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL + "/fast", Events: events})
+
+	const renames = 20
+	for i := 1; i <= renames; i++ {
+		resp := doJSON(t, creator.Token, http.MethodPut, fmt.Sprintf("/api/games/%d", gameID),
+			game.RenameGameRequest{Name: fmt.Sprintf("Sprint %d", i)})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	names := map[string][]string{}
+	for i := 0; i < 2*renames; i++ {
+		received := receiver.next(t)
+		var renamed game.GameRenamed
+		require.NoError(t, json.Unmarshal(received.event.Payload, &renamed))
+		names[received.path] = append(names[received.path], renamed.Name)
+	}
+	var expected []string
+	for i := 1; i <= renames; i++ {
+		expected = append(expected, fmt.Sprintf("Sprint %d", i))
+	}
+	require.Equal(t, expected, names["/slow"])
+	require.Equal(t, expected, names["/fast"])
+}
+
+func TestGlobalWebhooks(t *testing.T) {
+	receiver := startReceiver(t, func(string) int { return http.StatusOK })
+	cfg := game.DefaultConfig()
+	cfg.Webhooks.Global = []game.WebhookTarget{{
+		URL:    receiver.URL,
+		Secret: "global",
+		Events: []game.EventType{game.EventGameCreated, game.EventGameDeleted},
+	}}
+	cfg.AdminToken = "adm1n"
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+
+	gameID := createDefaultGame(t, creator)
+	received := receiver.next(t)
+	require.Equal(t, game.EventGameCreated, received.event.Type)
+	timestamp, err := strconv.ParseInt(received.header.Get(game.WebhookTimestampHeader), 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, game.SignWebhook("global", timestamp, received.body),
+		received.header.Get(game.WebhookSignatureHeader))
+	var created game.GameResponse
+	require.NoError(t, json.Unmarshal(received.event.Payload, &created))
+	require.Equal(t, gameID, created.ID)
+	require.Equal(t, creator.Player.ID, created.CreatedBy)
+
+	resp := doJSON(t, creator.Token, http.MethodDelete, fmt.Sprintf("/api/games/%d", gameID), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	received = receiver.next(t)
+	require.Equal(t, game.EventGameDeleted, received.event.Type)
+	require.Equal(t, gameID, received.event.GameID)
+
+	// deliveries to global webhooks are shown only to admins
+	var deliveries []game.WebhookDelivery
+	require.Eventually(t, func() bool {
+		resp := doJSON(t, "adm1n", http.MethodGet, "/api/webhooks/1/deliveries", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&deliveries))
+		return len(deliveries) == 2 && deliveries[0].Status == game.DeliveryDelivered
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, game.EventGameDeleted, deliveries[0].Event)
+	require.Equal(t, game.EventGameCreated, deliveries[1].Event)
+	resp = doJSON(t, creator.Token, http.MethodGet, "/api/webhooks/1/deliveries", nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, "adm1n", http.MethodGet, "/api/webhooks/2/deliveries", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	cfg.Webhooks.Global[0].URL = "ftp://example.com"
+	_, err = game.NewServer(cfg)
+	require.ErrorIs(t, err, game.ErrInvalidWebhook)
+
+	cfg.Webhooks.Global = nil
+	cfg.Webhooks.MaxBackoff = cfg.Webhooks.Backoff / 2
+	_, err = game.NewServer(cfg)
+	require.Error(t, err)
+}
+
+func TestWebhookErrors(t *testing.T) {
+	srv := startServerWithConfig(t, webhookConfig())
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator := createUser(t)
+	voter := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	join(t, voter, gameID)
+	hook := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: "http://127.0.0.1:9/hook"})
+
+	tests := []struct {
+		name         string
+		token        string
+		method       string
+		path         string
+		body         any
+		responseCode int
+		code         game.ErrorCode
+	}{
+		{
+			name:         "not facilitator",
+			token:        voter.Token,
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			body:         game.WebhookRequest{URL: "http://127.0.0.1:9/hook"},
+			responseCode: http.StatusForbidden,
+			code:         game.CodeForbidden,
+		},
+		{
+			name:         "not facilitator lists",
+			token:        voter.Token,
+			method:       http.MethodGet,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			responseCode: http.StatusForbidden,
+			code:         game.CodeForbidden,
+		},
+		{
+			name:         "not a URL",
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			body:         game.WebhookRequest{URL: "hook"},
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeValidationFailed,
+		},
+		{
+			name:         "not HTTP",
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			body:         game.WebhookRequest{URL: "ftp://localhost/hook"},
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidWebhook,
+		},
+		{
+			name:         "unknown event",
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			body:         game.WebhookRequest{URL: "http://127.0.0.1:9/hook", Events: []game.EventType{"game_snapshot"}},
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidWebhook,
+		},
+		{
+			name:         "host not allowed",
+			method:       http.MethodPost,
+			path:         fmt.Sprintf("/api/games/%d/webhooks", gameID),
+			body:         game.WebhookRequest{URL: "https://hooks.example.com/hook"},
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeInvalidWebhook,
+		},
+		{
+			name:         "unknown game",
+			method:       http.MethodPost,
+			path:         "/api/games/100/webhooks",
+			body:         game.WebhookRequest{URL: "http://127.0.0.1:9/hook"},
+			responseCode: http.StatusNotFound,
+			code:         game.CodeGameNotFound,
+		},
+		{
+			name:         "unknown webhook",
+			method:       http.MethodGet,
+			path:         fmt.Sprintf("/api/games/%d/webhooks/%d/deliveries", gameID, hook.ID+1),
+			responseCode: http.StatusNotFound,
+			code:         game.CodeWebhookNotFound,
+		},
+		{
+			name:         "bad webhook ID",
+			method:       http.MethodDelete,
+			path:         fmt.Sprintf("/api/games/%d/webhooks/first", gameID),
+			responseCode: http.StatusBadRequest,
+			code:         game.CodeBadRequest,
+		},
+		{
+			name:         "not facilitator removes",
+			token:        voter.Token,
+			method:       http.MethodDelete,
+			path:         fmt.Sprintf("/api/games/%d/webhooks/%d", gameID, hook.ID),
+			responseCode: http.StatusForbidden,
+			code:         game.CodeForbidden,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := test.token
+			if token == "" {
+				token = creator.Token
+			}
+			resp := doJSON(t, token, test.method, test.path, test.body)
+			require.Equal(t, test.responseCode, resp.StatusCode)
+			var errResp game.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+			require.Equal(t, test.code, errResp.Code)
+		})
+	}
+
+	for i := 1; i < 10; i++ {
+		addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: "http://127.0.0.1:9/hook"})
+	}
+	resp := doJSON(t, creator.Token, http.MethodPost, fmt.Sprintf("/api/games/%d/webhooks", gameID),
+		game.WebhookRequest{URL: "http://127.0.0.1:9/hook"})
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	var errResp game.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, game.CodeTooManyWebhooks, errResp.Code)
+}
+
+func TestArchivedGameDropsWebhookDeliveries(t *testing.T) {
+	clock := newFakeClock()
+	cfg := expiryConfig(clock, game.GameExpiryArchive)
+	cfg.Webhooks.GameHosts = []string{"127.0.0.1"}
+	srv := startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	receiver := startReceiver(t, func(string) int { return http.StatusOK })
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	hook := addWebhook(t, creator.Token, gameID, game.WebhookRequest{URL: receiver.URL})
+	join(t, createUser(t), gameID)
+	require.Equal(t, game.EventPlayerJoined, receiver.next(t).event.Type)
+	require.Len(t, webhookDeliveries(t, creator.Token, gameID, hook.ID), 1)
+
+	clock.Advance(testGameTTL + time.Second)
+	require.Equal(t, game.EventGameExpired, receiver.next(t).event.Type)
+	require.True(t, getGame(t, gameID).Archived)
+	require.Empty(t, webhookDeliveries(t, creator.Token, gameID, hook.ID))
+}
+
+func TestGameWebhookAddresses(t *testing.T) {
+	srv := startServer(t)
+	waitForServer(t)
+	creator := createUser(t)
+	gameID := createDefaultGame(t, creator)
+	path := fmt.Sprintf("/api/games/%d/webhooks", gameID)
+	resp := doJSON(t, creator.Token, http.MethodPost, path, game.WebhookRequest{URL: "https://hooks.example.com"})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	var errResp game.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, game.CodeWebhooksDisabled, errResp.Code)
+	require.NoError(t, srv.Stop(context.Background()))
+
+	cfg := game.DefaultConfig()
+	cfg.Webhooks.GameHosts = []string{"*"}
+	cfg.Webhooks.MaxAttempts = 1
+	srv = startServerWithConfig(t, cfg)
+	defer srv.Stop(context.Background())
+	waitForServer(t)
+	creator = createUser(t)
+	gameID = createDefaultGame(t, creator)
+	path = fmt.Sprintf("/api/games/%d/webhooks", gameID)
+	for _, url := range []string{
+		"http://127.0.0.1:8080/api/games",
+		"http://[::ffff:127.0.0.1]:9090/metrics",
+		"http://10.0.0.1/",
+		"http://169.254.169.254/latest/meta-data/",
+	} {
+		resp = doJSON(t, creator.Token, http.MethodPost, path, game.WebhookRequest{URL: url})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+	}
+
+	// a name is checked when it's resolved for every request, so it can't be pointed to a local address later
+	receiver := startReceiver(t, func(string) int { return http.StatusOK })
+	hook := addWebhook(t, creator.Token, gameID, game.WebhookRequest{
+		URL:    strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1),
+		Events: []game.EventType{game.EventRoundStarted},
+	})
+	newRound(t, creator.Token, gameID)
+	var deliveries []game.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries = webhookDeliveries(t, creator.Token, gameID, hook.ID)
+		return len(deliveries) == 1 && deliveries[0].Status == game.DeliveryFailed
+	}, time.Second, 10*time.Millisecond)
+	require.Zero(t, deliveries[0].Attempts[0].StatusCode)
+	require.Contains(t, deliveries[0].Attempts[0].Error, game.ErrAddressNotAllowed.Error())
+	require.Empty(t, receiver.received)
+}
+
+// webhookConfig allows webhooks of games to be sent to receivers of tests, which listen on the loopback address.
+func webhookConfig() game.Config {
+	cfg := game.DefaultConfig()
+	cfg.Webhooks.GameHosts = []string{"127.0.0.1"}
+	return cfg
+}
+
+type receivedWebhook struct {
+	path   string
+	header http.Header
+	body   []byte
+	event  game.Event
+}
+
+type webhookReceiver struct {
+	*httptest.Server
+	received chan receivedWebhook
+}
+
+// startReceiver starts a server that records webhook requests and answers them with the status returned by answer.
+func startReceiver(t *testing.T, answer func(path string) int) *webhookReceiver {
+	receiver := &webhookReceiver{received: make(chan receivedWebhook, 100)}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received := receivedWebhook{path: r.URL.Path, header: r.Header, body: body}
+		require.NoError(t, json.Unmarshal(body, &received.event))
+		receiver.received <- received
+		w.WriteHeader(answer(r.URL.Path))
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) next(t *testing.T) receivedWebhook {
+	select {
+	case received := <-r.received:
+		return received
+	case <-time.After(time.Second):
+		t.Fatal("No webhook was received in time")
+		return receivedWebhook{}
+	}
+}
+
+func addWebhook(t *testing.T, token string, gameID game.GameID, req game.WebhookRequest) game.Webhook {
+	resp := doJSON(t, token, http.MethodPost, fmt.Sprintf("/api/games/%d/webhooks", gameID), req)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var hook game.Webhook
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&hook))
+	return hook
+}
+
+func webhookDeliveries(t *testing.T, token string, gameID game.GameID, webhookID game.WebhookID) []game.WebhookDelivery {
+	path := fmt.Sprintf("/api/games/%d/webhooks/%d/deliveries", gameID, webhookID)
+	resp := doJSON(t, token, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var deliveries []game.WebhookDelivery
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&deliveries))
+	return deliveries
+}
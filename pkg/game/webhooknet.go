@@ -0,0 +1,116 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrWebhooksDisabled = errors.New("webhooks of games are disabled")
+var ErrAddressNotAllowed = errors.New("webhooks of games can't be sent to this address")
+
+// sharedAddressSpace is 100.64.0.0/10, used by carrier-grade NAT. netip doesn't count it as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// allowsGameHost tells whether webhooks of games can be sent to the host of a URL. See WebhooksConfig.GameHosts.
+func (cfg WebhooksConfig) allowsGameHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range cfg.GameHosts {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == "*" || allowed == host:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// validGameHost tells whether an entry of GameHosts is a host name, a pattern or an IP address rather than a URL.
+func validGameHost(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return true
+	}
+	return host != "" && !strings.ContainsAny(host, "/: ")
+}
+
+// allowedAddresses returns the IP addresses listed in GameHosts. Only these non-public addresses can get webhooks of
+// games.
+func (cfg WebhooksConfig) allowedAddresses() map[netip.Addr]bool {
+	addrs := make(map[netip.Addr]bool)
+	for _, host := range cfg.GameHosts {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			addrs[addr.Unmap()] = true
+		}
+	}
+	return addrs
+}
+
+// checkGameTarget rejects webhooks of games that the configuration doesn't allow. The target must be valid already.
+// Names are not resolved here, as they may resolve to another address later; the dialer of the game client checks
+// every connection instead.
+func (cfg WebhooksConfig) checkGameTarget(target WebhookTarget) error {
+	if len(cfg.GameHosts) == 0 {
+		return ErrWebhooksDisabled
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if !cfg.allowsGameHost(host) {
+		return fmt.Errorf("%w: host %q is not allowed", ErrInvalidWebhook, host)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err == nil && !publicAddress(addr) && !cfg.allowedAddresses()[addr.Unmap()] {
+		return fmt.Errorf("%w: %s", ErrInvalidWebhook, ErrAddressNotAllowed)
+	}
+	return nil
+}
+
+// publicAddress tells whether addr is reachable from the internet, so sending requests to it doesn't expose services
+// of the server's own network, like the metrics listener or a cloud metadata endpoint.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// newGameWebhookClient returns a client for webhooks of games. Its dialer refuses non-public addresses unless they are
+// allowed, and since it checks every connection, DNS rebinding or a redirect can't get around it. Proxies are not used,
+// as they would connect on the client's behalf.
+func newGameWebhookClient(cfg WebhooksConfig) *http.Client {
+	allowed := cfg.allowedAddresses()
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if addr := addrPort.Addr().Unmap(); !publicAddress(addr) && !allowed[addr] {
+				return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addr)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return newWebhookClient(cfg.Timeout, transport)
+}
+
+// newWebhookClient returns a client that doesn't follow redirects: a webhook has to answer with 2xx itself.
+func newWebhookClient(timeout time.Duration, transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}